@@ -2,12 +2,23 @@
 // and remove scattered time.Now() calls.
 package clock
 
-import "time"
+import (
+	"sort"
+	"sync"
+	"time"
+)
 
 // Clock abstracts time to allow fakes in tests.
 type Clock interface {
 	Now() time.Time
 	Sleep(d time.Duration)
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer created by a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
 }
 
 // Real returns the system clock.
@@ -15,19 +26,108 @@ var Real Clock = realClock{}
 
 type realClock struct{}
 
-func (realClock) Now() time.Time        { return time.Now() }
-func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+func (realClock) Now() time.Time                 { return time.Now() }
+func (realClock) Sleep(d time.Duration)          { time.Sleep(d) }
+func (realClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+type realTimer struct{ t *time.Timer }
 
-// Fake is a controllable clock for tests.
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+func (r realTimer) Stop() bool          { return r.t.Stop() }
+
+// Fake is a controllable clock for tests. Timers fire when the time is
+// advanced past their deadline. Safe for concurrent use.
 type Fake struct {
-	NowVal time.Time
+	NowVal  time.Time
+	mu      sync.Mutex
+	timers  []*fakeTimer
+	changed chan struct{} // closed when the set of timers changes
 }
 
 // Now returns the configured time.
-func (f *Fake) Now() time.Time { return f.NowVal }
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.NowVal
+}
 
 // Sleep advances NowVal by d (does not block).
-func (f *Fake) Sleep(d time.Duration) { f.NowVal = f.NowVal.Add(d) }
+func (f *Fake) Sleep(d time.Duration) { f.Advance(d) }
+
+// Advance advances the time by d and fires due timers in deadline order.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.NowVal = f.NowVal.Add(d)
+	sort.SliceStable(f.timers, func(i, j int) bool { return f.timers[i].deadline.Before(f.timers[j].deadline) })
+	pending := f.timers[:0]
+	for _, t := range f.timers {
+		if t.deadline.After(f.NowVal) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- f.NowVal
+	}
+	f.timers = pending
+	f.notify()
+}
+
+// NewTimer creates a timer that fires once the fake time reaches now+d.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTimer{f: f, deadline: f.NowVal.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- f.NowVal
+		return t
+	}
+	f.timers = append(f.timers, t)
+	f.notify()
+	return t
+}
 
-// Advance advances the time by d.
-func (f *Fake) Advance(d time.Duration) { f.NowVal = f.NowVal.Add(d) }
+// BlockUntil waits until at least n timers are pending (e.g. a scheduler is waiting).
+func (f *Fake) BlockUntil(n int) {
+	for {
+		f.mu.Lock()
+		if len(f.timers) >= n {
+			f.mu.Unlock()
+			return
+		}
+		if f.changed == nil {
+			f.changed = make(chan struct{})
+		}
+		ch := f.changed
+		f.mu.Unlock()
+		<-ch
+	}
+}
+
+// notify wakes BlockUntil callers; f.mu must be held.
+func (f *Fake) notify() {
+	if f.changed != nil {
+		close(f.changed)
+		f.changed = nil
+	}
+}
+
+type fakeTimer struct {
+	f        *Fake
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	for i, p := range t.f.timers {
+		if p == t {
+			t.f.timers = append(t.f.timers[:i], t.f.timers[i+1:]...)
+			t.f.notify()
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package cron
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/errors"
+)
+
+// JobInfo describes a registered job and its last run.
+type JobInfo struct {
+	Name         string
+	Spec         string
+	Next         time.Time // zero while the scheduler is not started
+	Prev         time.Time // start of the last run
+	LastDuration time.Duration
+	LastError    error
+	Running      bool
+	Paused       bool
+}
+
+// Jobs lists registered jobs sorted by name.
+func (s *Scheduler) Jobs() []JobInfo {
+	s.mu.Lock()
+	out := make([]JobInfo, 0, len(s.entries))
+	for _, e := range s.entries {
+		e.mu.Lock()
+		out = append(out, JobInfo{
+			Name:         e.name,
+			Spec:         e.spec,
+			Next:         e.next,
+			Prev:         e.lastStart,
+			LastDuration: e.lastDuration,
+			LastError:    e.lastErr,
+			Running:      e.running > 0,
+			Paused:       e.paused,
+		})
+		e.mu.Unlock()
+	}
+	s.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Trigger runs the job now in the background, outside its schedule, subject to
+// its overlap policy: with OverlapSkip it returns ErrJobRunning while a run is
+// going; with OverlapQueue the run starts after the current one.
+// Manual runs skip the Locker and do not update the StateStore.
+func (s *Scheduler) Trigger(name string) error {
+	e, err := s.entry(name)
+	if err != nil {
+		return err
+	}
+	switch s.submit(e, func() { s.runManual(e) }) {
+	case runSkipped:
+		return ErrJobRunning
+	case runStopped:
+		return errors.New(errors.CodeUnavailable, "cron: scheduler stopped")
+	}
+	return nil
+}
+
+func (s *Scheduler) runManual(e *entry) {
+	ctx := s.jobContext()
+	if ctx.Err() != nil {
+		return // shutting down (e.g. queued run)
+	}
+	_ = s.runJob(ctx, e, time.Time{}, nil)
+}
+
+// Pause skips the job's scheduled runs until Resume. Running runs are not affected.
+// Pause is held in memory only, but with a StateStore skipped ticks are recorded
+// as handled, so catch-up does not run them after a restart.
+func (s *Scheduler) Pause(name string) error { return s.setPaused(name, true) }
+
+// Resume re-enables the job's scheduled runs.
+func (s *Scheduler) Resume(name string) error { return s.setPaused(name, false) }
+
+func (s *Scheduler) setPaused(name string, paused bool) error {
+	e, err := s.entry(name)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.paused = paused
+	e.mu.Unlock()
+	return nil
+}
+
+func (s *Scheduler) entry(name string) (*entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return e, nil
+}
+
+func (e *entry) isPaused() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.paused
+}
+
+type jobView struct {
+	Name                string     `json:"name"`
+	Spec                string     `json:"spec"`
+	Next                *time.Time `json:"next,omitempty"`
+	Prev                *time.Time `json:"prev,omitempty"`
+	LastDurationSeconds float64    `json:"last_duration_seconds"`
+	LastError           string     `json:"last_error,omitempty"`
+	Running             bool       `json:"running"`
+	Paused              bool       `json:"paused"`
+}
+
+// Handler returns an admin HTTP handler:
+//
+//	GET  /jobs                 list jobs (JSON)
+//	POST /jobs/{name}/trigger  run now (409 if skipped by OverlapSkip)
+//	POST /jobs/{name}/pause    pause
+//	POST /jobs/{name}/resume   resume
+//
+// Mount it under a prefix with http.StripPrefix.
+func (s *Scheduler) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		jobs := s.Jobs()
+		views := make([]jobView, 0, len(jobs))
+		for _, j := range jobs {
+			v := jobView{
+				Name:                j.Name,
+				Spec:                j.Spec,
+				LastDurationSeconds: j.LastDuration.Seconds(),
+				Running:             j.Running,
+				Paused:              j.Paused,
+			}
+			if !j.Next.IsZero() {
+				v.Next = &j.Next
+			}
+			if !j.Prev.IsZero() {
+				v.Prev = &j.Prev
+			}
+			if j.LastError != nil {
+				v.LastError = j.LastError.Error()
+			}
+			views = append(views, v)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(views)
+	})
+	action := func(fn func(name string) error, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if err := fn(r.PathValue("name")); err != nil {
+				http.Error(w, err.Error(), errors.HTTPStatus(err))
+				return
+			}
+			w.WriteHeader(status)
+		}
+	}
+	mux.Handle("POST /jobs/{name}/trigger", action(s.Trigger, http.StatusAccepted))
+	mux.Handle("POST /jobs/{name}/pause", action(s.Pause, http.StatusNoContent))
+	mux.Handle("POST /jobs/{name}/resume", action(s.Resume, http.StatusNoContent))
+	return mux
+}
@@ -1,14 +1,33 @@
-// Package cron provides a wrapper for robfig/cron for job scheduling.
-// Distributed lock and per-job metrics can be added later.
+// Package cron provides job scheduling with robfig/cron specs and overlap policies,
+// driven by clock.Clock, with optional distributed locking and per-job metrics.
 package cron
 
 import (
 	"context"
+	"sort"
 	"sync"
+	"time"
 
+	"github.com/cosmos-toolkit/pkgs/pkg/clock"
+	"github.com/cosmos-toolkit/pkgs/pkg/errors"
 	"github.com/robfig/cron/v3"
 )
 
+// DefaultShutdownTimeout is how long Start and Stop wait for running jobs.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// ErrJobNotFound is returned for an unknown job name.
+var ErrJobNotFound = errors.New(errors.CodeNotFound, "cron: job not found")
+
+// ErrJobRunning is returned by Trigger when the job's OverlapSkip policy drops the run.
+var ErrJobRunning = errors.New(errors.CodeConflict, "cron: job is running")
+
+// ErrShutdownTimeout is returned when running jobs did not return within the shutdown timeout.
+var ErrShutdownTimeout = errors.New(errors.CodeTimeout, "cron: timeout waiting for running jobs")
+
+// specFields are the fields accepted by default: 5-field specs and descriptors (@daily, @every 1h, ...).
+const specFields = cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor
+
 // Job is a scheduled task.
 type Job interface {
 	Run(ctx context.Context) error
@@ -19,56 +38,488 @@ type JobFunc func(ctx context.Context) error
 
 func (f JobFunc) Run(ctx context.Context) error { return f(ctx) }
 
+// Option configures the Scheduler.
+type Option func(*Scheduler)
+
+// WithShutdownTimeout sets how long shutdown waits for running jobs (default DefaultShutdownTimeout).
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(s *Scheduler) { s.shutdownTimeout = d }
+}
+
+// WithLocation sets the default time zone of specs (default time.Local).
+// A job can override it with a "CRON_TZ=America/Sao_Paulo " prefix in its spec.
+func WithLocation(loc *time.Location) Option {
+	return func(s *Scheduler) {
+		if loc != nil {
+			s.location = loc
+		}
+	}
+}
+
+// WithSeconds accepts an optional leading seconds field ("*/10 * * * * *").
+func WithSeconds() Option {
+	return func(s *Scheduler) { s.fields |= cron.SecondOptional }
+}
+
+// WithClock drives the scheduler with c (default clock.Real). With a clock.Fake,
+// tests advance time to fire jobs and observe runs with WithRunHook. Jobs due at
+// the same tick start in name order: each run (without jitter) has started, or
+// was skipped, before the next one is dispatched; they then run concurrently.
+// The shutdown timeout always uses wall-clock time.
+func WithClock(c clock.Clock) Option {
+	return func(s *Scheduler) {
+		if c != nil {
+			s.clock = c
+		}
+	}
+}
+
+// WithStateStore records each job's last successful tick in st and enables
+// catch-up of missed runs on Start (see WithCatchUp).
+func WithStateStore(st StateStore) Option {
+	return func(s *Scheduler) { s.state = st }
+}
+
+// WithErrorHandler sets fn to receive the error of failed runs (after retries)
+// and of lock or state store operations. Errors are dropped by default.
+func WithErrorHandler(fn func(ctx context.Context, name string, err error)) Option {
+	return func(s *Scheduler) { s.onError = fn }
+}
+
+// WithLocker makes every run acquire l first, so that a job runs on a single
+// replica per tick. ttl bounds how long a crashed run holds the lock (default DefaultLockTTL).
+// "@every" schedules are not aligned across replicas; the lock then only prevents overlap.
+func WithLocker(l Locker, ttl time.Duration) Option {
+	return func(s *Scheduler) {
+		if ttl <= 0 {
+			ttl = DefaultLockTTL
+		}
+		s.locker, s.lockTTL = l, ttl
+	}
+}
+
+// RunEvent is reported to the WithRunHook function when a run starts and when it returns.
+type RunEvent struct {
+	Name     string
+	Tick     time.Time // scheduled tick; zero for Trigger
+	Finished bool      // false when the run starts, true when the job returned
+	Err      error     // job error, when Finished
+}
+
+// WithRunHook calls fn when each run starts and when it returns (e.g. tests
+// waiting for the jobs fired by a clock.Fake). fn runs on the job's goroutine
+// and must not block.
+func WithRunHook(fn func(RunEvent)) Option {
+	return func(s *Scheduler) { s.onRun = fn }
+}
+
 // Scheduler schedules and runs jobs.
 type Scheduler struct {
-	cron    *cron.Cron
-	entries map[string]cron.EntryID
-	mu      sync.Mutex
+	clock           clock.Clock
+	entries         map[string]*entry
+	wake            chan struct{} // entries changed
+	mu              sync.Mutex
+	ctx             context.Context // parent of every job ctx; cancelled on shutdown
+	cancel          context.CancelFunc
+	shutdownTimeout time.Duration
+	location        *time.Location
+	fields          cron.ParseOption
+	parser          cron.Parser
+	locker          Locker
+	lockTTL         time.Duration
+	inst            *instrumentation
+	state           StateStore
+	onError         func(ctx context.Context, name string, err error)
+	onRun           func(RunEvent)
+	running         sync.WaitGroup // every run goroutine
 }
 
+type entry struct {
+	name   string
+	spec   string
+	sched  cron.Schedule
+	job    Job
+	opts   jobOptions
+	next   time.Time // next activation; guarded by Scheduler.mu
+	mu     sync.Mutex
+	tick   time.Time // latest tick run
+	active bool      // a run holds the overlap gate (see submit)
+	queued func()    // run pending under OverlapQueue
+	// run info (see Jobs)
+	paused       bool
+	running      int
+	lastStart    time.Time
+	lastDuration time.Duration
+	lastErr      error
+}
+
+// advance records tick and returns how many activations were skipped since the previous one.
+func (e *entry) advance(tick time.Time) int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	prev := e.tick
+	if tick.Before(prev) {
+		return 0
+	}
+	e.tick = tick
+	if prev.IsZero() {
+		return 0
+	}
+	missed := 0
+	for t := e.sched.Next(prev); !t.IsZero() && t.Before(tick) && missed < maxMissedCount; t = e.sched.Next(t) {
+		missed++
+	}
+	return missed
+}
+
+// maxMissedCount bounds the missed-run scan after long pauses.
+const maxMissedCount = 1000
+
 // New creates a scheduler. Use Start() to begin.
-func New() *Scheduler {
-	return &Scheduler{
-		cron:    cron.New(),
-		entries: make(map[string]cron.EntryID),
+func New(opts ...Option) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		clock:           clock.Real,
+		entries:         make(map[string]*entry),
+		wake:            make(chan struct{}, 1),
+		ctx:             ctx,
+		cancel:          cancel,
+		shutdownTimeout: DefaultShutdownTimeout,
+		location:        time.Local,
+		fields:          specFields,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.parser = cron.NewParser(s.fields)
+	return s
 }
 
-// Add registers a job to run at spec (cron format: "0 * * * *" = every minute).
+// Add registers a job to run at spec (cron format: "0 * * * *" = every hour;
+// descriptors such as "@daily" or "@every 10m"; optional "CRON_TZ=<zone> " prefix).
 // name identifies the job (for removal later).
-func (s *Scheduler) Add(name, spec string, job Job) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	id, err := s.cron.AddFunc(spec, func() {
-		_ = job.Run(context.Background())
-	})
+// The job ctx is cancelled when the scheduler shuts down.
+// An invalid spec returns an error with code errors.CodeInvalidInput.
+func (s *Scheduler) Add(name, spec string, job Job, opts ...JobOption) error {
+	sched, err := s.parser.Parse(spec)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, errors.CodeInvalidInput, "cron: invalid spec %q for job %s", spec, name)
+	}
+	e := &entry{name: name, spec: spec, sched: sched}
+	for _, opt := range opts {
+		opt(&e.opts)
 	}
-	s.entries[name] = id
+	e.job = e.opts.wrap(job)
+	s.mu.Lock()
+	s.entries[name] = e
+	s.mu.Unlock()
+	s.wakeup()
 	return nil
 }
 
+func (s *Scheduler) now() time.Time { return s.clock.Now().In(s.location) }
+
+func (s *Scheduler) wakeup() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop fires due entries and sleeps on the clock until the next activation or ctx is done.
+func (s *Scheduler) loop(ctx context.Context) {
+	for {
+		now := s.now()
+		var due []dueRun
+		var next time.Time
+		s.mu.Lock()
+		for _, e := range s.entries {
+			if e.next.IsZero() {
+				e.next = e.sched.Next(now)
+			}
+			if e.next.IsZero() {
+				continue // spec never fires (e.g. "0 0 30 2 *")
+			}
+			if !e.next.After(now) {
+				due = append(due, dueRun{e, latestTick(e.sched, e.next, now)})
+				e.next = e.sched.Next(now)
+			}
+			if !e.next.IsZero() && (next.IsZero() || e.next.Before(next)) {
+				next = e.next
+			}
+		}
+		s.mu.Unlock()
+
+		// dispatch in name order, each once its run started
+		sort.Slice(due, func(i, j int) bool { return due[i].e.name < due[j].e.name })
+		for _, d := range due {
+			started := make(chan struct{})
+			signal := sync.OnceFunc(func() { close(started) })
+			if s.submit(d.e, func() { defer signal(); s.run(d.e, d.tick, signal) }) == runStarted {
+				<-started
+			}
+		}
+
+		var timer clock.Timer
+		var timerC <-chan time.Time
+		if !next.IsZero() {
+			timer = s.clock.NewTimer(next.Sub(now))
+			timerC = timer.C()
+		}
+		select {
+		case <-ctx.Done():
+		case <-timerC:
+		case <-s.wake:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// dueRun is an entry fired by the loop for tick.
+type dueRun struct {
+	e    *entry
+	tick time.Time
+}
+
+// latestTick returns the last activation at or before now, starting from first.
+func latestTick(sched cron.Schedule, first, now time.Time) time.Time {
+	tick := first
+	for i := 0; i < maxMissedCount; i++ {
+		next := sched.Next(tick)
+		if next.IsZero() || next.After(now) {
+			break
+		}
+		tick = next
+	}
+	return tick
+}
+
+// goRun runs fn in a goroutine that shutdown waits for, and reports false if the
+// scheduler is shutting down. The check and running.Add happen under s.mu, which
+// shutdown holds to cancel, so no run is added once shutdown waits.
+func (s *Scheduler) goRun(fn func()) bool {
+	s.mu.Lock()
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return false
+	}
+	s.running.Add(1)
+	s.mu.Unlock()
+	go func() {
+		defer s.running.Done()
+		fn()
+	}()
+	return true
+}
+
+// run is a scheduled run of e for tick; started is called when the job starts,
+// or before the jitter delay.
+func (s *Scheduler) run(e *entry, tick time.Time, started func()) {
+	ctx := s.jobContext()
+	if ctx.Err() != nil {
+		return // shutting down (e.g. queued run)
+	}
+	s.inst.missedRuns(e.name, e.advance(tick))
+	if e.isPaused() {
+		s.skipPaused(ctx, e, tick)
+		return
+	}
+	if d := e.opts.jitterDelay(); d > 0 {
+		started()
+		t := s.clock.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C():
+		}
+	}
+	s.execute(ctx, e, tick, started)
+}
+
+// execute runs the job for tick: lock, instrumentation and state.
+func (s *Scheduler) execute(ctx context.Context, e *entry, tick time.Time, started func()) {
+	if s.locker != nil {
+		ok, err := s.locker.Lock(ctx, e.name, tick, s.lockTTL)
+		if err != nil {
+			s.inst.missedRuns(e.name, 1)
+			s.reportErr(ctx, e.name, err)
+			return
+		}
+		if !ok {
+			return
+		}
+		defer func() {
+			s.reportErr(ctx, e.name, s.locker.Unlock(context.WithoutCancel(ctx), e.name, tick))
+		}()
+	}
+	if err := s.runJob(ctx, e, tick, started); err == nil && s.state != nil {
+		s.reportErr(ctx, e.name, s.state.SetLastRun(context.WithoutCancel(ctx), e.name, tick))
+	}
+}
+
+// skipPaused records tick as handled, so that catch-up does not replay ticks
+// skipped while the job was paused after a restart.
+func (s *Scheduler) skipPaused(ctx context.Context, e *entry, tick time.Time) {
+	if s.state != nil {
+		s.reportErr(ctx, e.name, s.state.SetLastRun(context.WithoutCancel(ctx), e.name, tick))
+	}
+}
+
+func (s *Scheduler) hook(ev RunEvent) {
+	if s.onRun != nil {
+		s.onRun(ev)
+	}
+}
+
+func (s *Scheduler) reportErr(ctx context.Context, name string, err error) {
+	if err != nil && s.onError != nil {
+		s.onError(ctx, name, err)
+	}
+}
+
+// runJob runs the job with timeout and instrumentation, records run info and reports
+// errors. tick is zero for manual runs; started, if not nil, is called once the run started.
+func (s *Scheduler) runJob(ctx context.Context, e *entry, tick time.Time, started func()) error {
+	if e.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.opts.timeout)
+		defer cancel()
+	}
+	start := s.clock.Now()
+	e.mu.Lock()
+	e.running++
+	e.lastStart = start
+	e.mu.Unlock()
+	s.hook(RunEvent{Name: e.name, Tick: tick})
+	if started != nil {
+		started()
+	}
+	err := s.inst.run(ctx, e.name, e.job)
+	e.mu.Lock()
+	e.running--
+	e.lastDuration = s.clock.Now().Sub(start)
+	e.lastErr = err
+	e.mu.Unlock()
+	s.reportErr(ctx, e.name, err)
+	s.hook(RunEvent{Name: e.name, Tick: tick, Finished: true, Err: err})
+	return err
+}
+
+// catchUp runs ticks missed since the job's last recorded run, per its policy,
+// as a single run through the overlap policy; it stops while the job is paused.
+func (s *Scheduler) catchUp(ctx context.Context, e *entry, now time.Time) {
+	last, err := s.state.LastRun(ctx, e.name)
+	if err != nil || last.IsZero() {
+		s.reportErr(ctx, e.name, err)
+		return
+	}
+	keep := 1
+	if e.opts.catchUp == CatchUpAll {
+		keep = e.opts.catchUpLimit
+	}
+	// keep the most recent ticks; older ones are dropped
+	var missed []time.Time
+	total := 0
+	for t := e.sched.Next(last.In(s.location)); !t.IsZero() && !t.After(now) && total < maxCatchUpScan; t = e.sched.Next(t) {
+		total++
+		if len(missed) == keep {
+			missed = append(missed[:0], missed[1:]...)
+		}
+		missed = append(missed, t)
+	}
+	if len(missed) == 0 {
+		return
+	}
+	s.inst.missedRuns(e.name, total-len(missed))
+	res := s.submit(e, func() {
+		for _, tick := range missed {
+			if ctx.Err() != nil {
+				return
+			}
+			if e.isPaused() {
+				s.skipPaused(ctx, e, missed[len(missed)-1])
+				return
+			}
+			e.advance(tick)
+			s.execute(ctx, e, tick, nil)
+		}
+	})
+	if res == runSkipped {
+		s.inst.missedRuns(e.name, len(missed))
+	}
+}
+
+// maxCatchUpScan bounds the catch-up scan after long downtimes.
+const maxCatchUpScan = 100000
+
+func (s *Scheduler) jobContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ctx
+}
+
 // Remove removes a job by name.
 func (s *Scheduler) Remove(name string) {
 	s.mu.Lock()
-	id, ok := s.entries[name]
 	delete(s.entries, name)
 	s.mu.Unlock()
-	if ok {
-		s.cron.Remove(id)
-	}
+	s.wakeup()
 }
 
-// Start starts the scheduler. Blocks until ctx is cancelled.
+// Start starts the scheduler and, with a StateStore, catches up missed runs in
+// the background. Blocks until ctx is cancelled, then cancels the
+// ctx of running jobs and waits up to the shutdown timeout for them to return.
+// Job contexts derive from ctx (values are propagated).
 func (s *Scheduler) Start(ctx context.Context) error {
-	s.cron.Start()
-	<-ctx.Done()
-	s.cron.Stop()
+	s.mu.Lock()
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	jobCtx := s.ctx
+	now := s.now()
+	var catchUp []*entry
+	for _, e := range s.entries {
+		e.next = e.sched.Next(now)
+		if s.state != nil && e.opts.catchUp != CatchUpNone {
+			catchUp = append(catchUp, e)
+		}
+	}
+	s.mu.Unlock()
+	for _, e := range catchUp {
+		s.goRun(func() { s.catchUp(jobCtx, e, now) })
+	}
+	s.loop(jobCtx)
+	if err := s.shutdown(); err != nil {
+		return err
+	}
 	return ctx.Err()
 }
 
-// Stop stops the scheduler.
-func (s *Scheduler) Stop() {
-	s.cron.Stop()
+// Stop stops the scheduler, cancels running jobs and waits up to the shutdown timeout.
+// Start then returns.
+func (s *Scheduler) Stop() error {
+	return s.shutdown()
+}
+
+func (s *Scheduler) shutdown() error {
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	t := time.NewTimer(s.shutdownTimeout)
+	defer t.Stop()
+	select {
+	case <-done:
+		return nil
+	case <-t.C:
+		return ErrShutdownTimeout
+	}
 }
@@ -0,0 +1,84 @@
+// Package cron: per-job metrics and tracing for the Scheduler.
+
+package cron
+
+import (
+	"context"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/metrics"
+	"github.com/cosmos-toolkit/pkgs/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// InstrumentedConfig configures scheduler instrumentation.
+type InstrumentedConfig struct {
+	TracerName string
+	MetricName string                // metric name prefix
+	Registerer prometheus.Registerer // default: prometheus.DefaultRegisterer
+}
+
+// DefaultInstrumentedConfig returns default instrumentation config.
+func DefaultInstrumentedConfig() InstrumentedConfig {
+	return InstrumentedConfig{
+		TracerName: "cron",
+		MetricName: "cron_jobs",
+	}
+}
+
+// WithInstrumentation records a span and metrics for each run, labelled by job name:
+// duration, successes, failures, last success timestamp and missed runs.
+func WithInstrumentation(cfg InstrumentedConfig) Option {
+	return func(s *Scheduler) {
+		reg, name := cfg.Registerer, cfg.MetricName
+		s.inst = &instrumentation{
+			tracerName:  cfg.TracerName,
+			duration:    metrics.HistogramVecWith(reg, name+"_duration_seconds", "Cron job run duration in seconds", nil, "job"),
+			success:     metrics.CounterVecWith(reg, name+"_success_total", "Total successful cron job runs", "job"),
+			failures:    metrics.CounterVecWith(reg, name+"_failures_total", "Total failed cron job runs", "job"),
+			lastSuccess: metrics.GaugeVecWith(reg, name+"_last_success_timestamp_seconds", "Unix time of the last successful run", "job"),
+			missed:      metrics.CounterVecWith(reg, name+"_missed_total", "Total scheduled runs that did not run", "job"),
+		}
+	}
+}
+
+type instrumentation struct {
+	tracerName  string
+	duration    *prometheus.HistogramVec
+	success     *prometheus.CounterVec
+	failures    *prometheus.CounterVec
+	lastSuccess *prometheus.GaugeVec
+	missed      *prometheus.CounterVec
+}
+
+// run executes job; a nil instrumentation just runs it.
+func (i *instrumentation) run(ctx context.Context, name string, job Job) error {
+	if i == nil {
+		return job.Run(ctx)
+	}
+	start := time.Now()
+	ctx, span := tracing.StartSpan(ctx, i.tracerName, "job")
+	span.SetAttributes(attribute.String("job.name", name))
+	defer span.End()
+
+	err := job.Run(ctx)
+	i.duration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.Bool("error", true))
+		i.failures.WithLabelValues(name).Inc()
+		return err
+	}
+	i.success.WithLabelValues(name).Inc()
+	i.lastSuccess.WithLabelValues(name).SetToCurrentTime()
+	return nil
+}
+
+func (i *instrumentation) missedRuns(name string, n int) {
+	if i == nil || n <= 0 {
+		return
+	}
+	i.missed.WithLabelValues(name).Add(float64(n))
+}
@@ -0,0 +1,130 @@
+package cron
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/clock"
+	"github.com/cosmos-toolkit/pkgs/pkg/db"
+)
+
+// DefaultLockTTL is the default lease of a job lock (should exceed the longest run).
+const DefaultLockTTL = 10 * time.Minute
+
+// Locker ensures a job runs on a single replica per scheduled tick.
+type Locker interface {
+	// Lock acquires name for the run scheduled at tick, held for at most ttl.
+	// Returns false if another owner holds it or the tick has already run.
+	Lock(ctx context.Context, name string, tick time.Time, ttl time.Duration) (bool, error)
+	// Unlock releases name after the run; the tick stays recorded.
+	Unlock(ctx context.Context, name string, tick time.Time) error
+}
+
+// MemoryLocker implements Locker in memory (tests, or several schedulers in one process).
+// The zero value is ready to use.
+type MemoryLocker struct {
+	Clock clock.Clock // lease expiry; default clock.Real
+	mu    sync.Mutex
+	locks map[string]*memoryLock
+}
+
+type memoryLock struct {
+	tick  time.Time
+	until time.Time
+}
+
+// NewMemoryLocker creates an in-memory locker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{Clock: clock.Real, locks: make(map[string]*memoryLock)}
+}
+
+// Lock implements Locker.
+func (l *MemoryLocker) Lock(ctx context.Context, name string, tick time.Time, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Clock == nil {
+		l.Clock = clock.Real
+	}
+	if l.locks == nil {
+		l.locks = make(map[string]*memoryLock)
+	}
+	now := l.Clock.Now()
+	if cur, ok := l.locks[name]; ok && (!cur.tick.Before(tick) || cur.until.After(now)) {
+		return false, nil
+	}
+	l.locks[name] = &memoryLock{tick: tick, until: now.Add(ttl)}
+	return true, nil
+}
+
+// Unlock implements Locker.
+func (l *MemoryLocker) Unlock(ctx context.Context, name string, tick time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if cur, ok := l.locks[name]; ok && cur.tick.Equal(tick) {
+		cur.until = time.Time{}
+	}
+	return nil
+}
+
+// SQLLocker implements Locker with a lease table (name primary key, tick, owner, locked_until).
+type SQLLocker struct {
+	DB      *sql.DB
+	Table   string     // default "cron_locks"; optionally schema-qualified
+	Owner   string     // default db.DefaultOwner
+	Dialect db.Dialect // default db.Portable
+}
+
+func (l *SQLLocker) query(q string) (string, error) {
+	return db.Table{Name: cmp.Or(l.Table, "cron_locks"), Dialect: l.Dialect}.Query(q)
+}
+
+func (l *SQLLocker) owner() string {
+	return cmp.Or(l.Owner, db.DefaultOwner())
+}
+
+// Lock takes over the row when its tick is older and its lease expired, or inserts it.
+func (l *SQLLocker) Lock(ctx context.Context, name string, tick time.Time, ttl time.Duration) (bool, error) {
+	upd, err := l.query(`UPDATE {table} SET tick = ?, owner = ?, locked_until = ? WHERE name = ? AND tick < ? AND locked_until <= ?`)
+	if err != nil {
+		return false, err
+	}
+	ins, err := l.query(`INSERT INTO {table} (name, tick, owner, locked_until) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return false, err
+	}
+	count, err := l.query(`SELECT COUNT(*) FROM {table} WHERE name = ?`)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	res, err := l.DB.ExecContext(ctx, upd, tick, l.owner(), now.Add(ttl), name, tick, now)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return true, nil
+	}
+	_, err = l.DB.ExecContext(ctx, ins, name, tick, l.owner(), now.Add(ttl))
+	if err == nil {
+		return true, nil
+	}
+	// insert failed: either the row exists (held or tick done) or a real error
+	var n int
+	if qerr := l.DB.QueryRowContext(ctx, count, name).Scan(&n); qerr != nil || n == 0 {
+		return false, err
+	}
+	return false, nil
+}
+
+// Unlock releases the lease held by this owner for tick.
+func (l *SQLLocker) Unlock(ctx context.Context, name string, tick time.Time) error {
+	query, err := l.query(`UPDATE {table} SET locked_until = ? WHERE name = ? AND tick = ? AND owner = ?`)
+	if err != nil {
+		return err
+	}
+	_, err = l.DB.ExecContext(ctx, query, time.Now(), name, tick, l.owner())
+	return err
+}
@@ -0,0 +1,143 @@
+package cron
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/retry"
+)
+
+// OverlapPolicy defines what happens when a job is due while its previous run is still going.
+type OverlapPolicy int
+
+const (
+	OverlapAllow OverlapPolicy = iota // start another run concurrently (default)
+	OverlapSkip                       // skip the activation
+	OverlapQueue                      // run once more after the current run; further activations replace the pending one
+)
+
+// JobOption configures a job registered with Add.
+type JobOption func(*jobOptions)
+
+type jobOptions struct {
+	overlap      OverlapPolicy
+	catchUp      CatchUpPolicy
+	catchUpLimit int
+	retry        *retry.Config
+	timeout      time.Duration
+	jitter       time.Duration
+}
+
+// WithRetry retries a failed run within the same tick (backoff uses wall-clock time).
+func WithRetry(cfg retry.Config) JobOption {
+	return func(o *jobOptions) { o.retry = &cfg }
+}
+
+// WithTimeout bounds each run, retries included, by cancelling the job ctx after d.
+func WithTimeout(d time.Duration) JobOption {
+	return func(o *jobOptions) { o.timeout = d }
+}
+
+// WithJitter delays each scheduled run by a random duration in [0, maxDelay)
+// to spread load when many jobs share a spec.
+func WithJitter(maxDelay time.Duration) JobOption {
+	return func(o *jobOptions) { o.jitter = maxDelay }
+}
+
+// wrap applies retry to job.
+func (o jobOptions) wrap(job Job) Job {
+	if o.retry == nil {
+		return job
+	}
+	cfg := *o.retry
+	return JobFunc(func(ctx context.Context) error {
+		return retry.Do(ctx, cfg, func() error { return job.Run(ctx) })
+	})
+}
+
+func (o jobOptions) jitterDelay() time.Duration {
+	if o.jitter <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(o.jitter)))
+}
+
+// WithOverlap sets the job's overlap policy.
+func WithOverlap(p OverlapPolicy) JobOption {
+	return func(o *jobOptions) { o.overlap = p }
+}
+
+// CatchUpPolicy defines which runs missed while the process was down are run on Start.
+// Requires a StateStore (see WithStateStore).
+type CatchUpPolicy int
+
+const (
+	CatchUpNone CatchUpPolicy = iota // missed runs are lost (default)
+	CatchUpOnce                      // run once if at least one run was missed
+	CatchUpAll                       // run the missed ticks, oldest first, up to a limit
+)
+
+// DefaultCatchUpLimit bounds CatchUpAll when no limit is given.
+const DefaultCatchUpLimit = 100
+
+// WithCatchUp sets the job's catch-up policy. limit applies to CatchUpAll
+// (default DefaultCatchUpLimit): only the most recent limit missed ticks run,
+// older ones are dropped and counted as missed runs.
+func WithCatchUp(p CatchUpPolicy, limit int) JobOption {
+	return func(o *jobOptions) {
+		if limit <= 0 {
+			limit = DefaultCatchUpLimit
+		}
+		o.catchUp, o.catchUpLimit = p, limit
+	}
+}
+
+// submitResult is the outcome of Scheduler.submit.
+type submitResult int
+
+const (
+	runStarted submitResult = iota
+	runQueued               // pending until the current run returns
+	runSkipped              // dropped by OverlapSkip
+	runStopped              // scheduler shutting down
+)
+
+// submit starts fn in the background according to the job's overlap policy.
+// Every run of a job (scheduled, catch-up or manual) goes through submit.
+func (s *Scheduler) submit(e *entry, fn func()) submitResult {
+	if e.opts.overlap == OverlapAllow {
+		if !s.goRun(fn) {
+			return runStopped
+		}
+		return runStarted
+	}
+	e.mu.Lock()
+	if e.active {
+		if e.opts.overlap == OverlapSkip {
+			e.mu.Unlock()
+			return runSkipped
+		}
+		e.queued = fn // keep the latest activation only
+		e.mu.Unlock()
+		return runQueued
+	}
+	e.active = true
+	e.mu.Unlock()
+	started := s.goRun(func() {
+		for fn != nil {
+			fn()
+			e.mu.Lock()
+			fn, e.queued = e.queued, nil
+			e.active = fn != nil
+			e.mu.Unlock()
+		}
+	})
+	if !started {
+		e.mu.Lock()
+		e.active = false
+		e.mu.Unlock()
+		return runStopped
+	}
+	return runStarted
+}
@@ -0,0 +1,139 @@
+package cron
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/db"
+)
+
+// StateStore persists the tick of each job's last successful run.
+type StateStore interface {
+	// LastRun returns the last recorded tick, or the zero time if none.
+	LastRun(ctx context.Context, name string) (time.Time, error)
+	// SetLastRun records tick unless a later one is already recorded.
+	SetLastRun(ctx context.Context, name string, tick time.Time) error
+}
+
+// SQLStateStore implements StateStore with a table (name primary key, last_run).
+type SQLStateStore struct {
+	DB      *sql.DB
+	Table   string     // default "cron_state"; optionally schema-qualified
+	Dialect db.Dialect // default db.Portable
+}
+
+func (s *SQLStateStore) query(q string) (string, error) {
+	return db.Table{Name: cmp.Or(s.Table, "cron_state"), Dialect: s.Dialect}.Query(q)
+}
+
+// LastRun implements StateStore.
+func (s *SQLStateStore) LastRun(ctx context.Context, name string) (time.Time, error) {
+	query, err := s.query(`SELECT last_run FROM {table} WHERE name = ?`)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var t time.Time
+	err = s.DB.QueryRowContext(ctx, query, name).Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	return t, err
+}
+
+// SetLastRun implements StateStore.
+func (s *SQLStateStore) SetLastRun(ctx context.Context, name string, tick time.Time) error {
+	upd, err := s.query(`UPDATE {table} SET last_run = ? WHERE name = ? AND last_run < ?`)
+	if err != nil {
+		return err
+	}
+	ins, err := s.query(`INSERT INTO {table} (name, last_run) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	res, err := s.DB.ExecContext(ctx, upd, tick, name, tick)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil
+	}
+	_, err = s.DB.ExecContext(ctx, ins, name, tick)
+	if err == nil {
+		return nil
+	}
+	// insert failed: the row exists with a later tick, or a real error
+	if last, qerr := s.LastRun(ctx, name); qerr == nil && !last.IsZero() {
+		return nil
+	}
+	return err
+}
+
+// FileStateStore implements StateStore with a JSON file (single instance deployments).
+type FileStateStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (f *FileStateStore) load() (map[string]time.Time, error) {
+	state := make(map[string]time.Time)
+	data, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return state, nil
+	}
+	return state, json.Unmarshal(data, &state)
+}
+
+// LastRun implements StateStore.
+func (f *FileStateStore) LastRun(ctx context.Context, name string) (time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, err := f.load()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return state[name], nil
+}
+
+// SetLastRun implements StateStore. The file is replaced atomically.
+func (f *FileStateStore) SetLastRun(ctx context.Context, name string, tick time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	state, err := f.load()
+	if err != nil {
+		return err
+	}
+	if !state[name].Before(tick) {
+		return nil
+	}
+	state[name] = tick
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
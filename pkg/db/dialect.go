@@ -0,0 +1,93 @@
+package db
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the SQL differences between databases.
+type Dialect interface {
+	Name() string
+	// Placeholder returns the n-th (1-based) bind parameter ("?" or "$1").
+	Placeholder(n int) string
+	// Quote quotes a single identifier (validate it first with QuoteIdent).
+	Quote(ident string) string
+	// Returning reports whether INSERT ... RETURNING is supported; otherwise use LastInsertId.
+	Returning() bool
+	// SkipLocked returns the clause that locks selected rows, skipping rows locked
+	// by other transactions, or "" if unsupported.
+	SkipLocked() string
+}
+
+// Supported dialects.
+var (
+	Postgres Dialect = postgres{}
+	MySQL    Dialect = mysql{} // MySQL 8+ for SKIP LOCKED
+	SQLite   Dialect = sqlite{}
+)
+
+type postgres struct{}
+
+func (postgres) Name() string              { return "postgres" }
+func (postgres) Placeholder(n int) string  { return "$" + strconv.Itoa(n) }
+func (postgres) Quote(ident string) string { return `"` + ident + `"` }
+func (postgres) Returning() bool           { return true }
+func (postgres) SkipLocked() string        { return "FOR UPDATE SKIP LOCKED" }
+
+type mysql struct{}
+
+func (mysql) Name() string              { return "mysql" }
+func (mysql) Placeholder(int) string    { return "?" }
+func (mysql) Quote(ident string) string { return "`" + ident + "`" }
+func (mysql) Returning() bool           { return false }
+func (mysql) SkipLocked() string        { return "FOR UPDATE SKIP LOCKED" }
+
+type sqlite struct{}
+
+func (sqlite) Name() string              { return "sqlite" }
+func (sqlite) Placeholder(int) string    { return "?" }
+func (sqlite) Quote(ident string) string { return `"` + ident + `"` }
+func (sqlite) Returning() bool           { return false }
+func (sqlite) SkipLocked() string        { return "" }
+
+// ErrInvalidIdentifier is returned by QuoteIdent for names that are not plain identifiers.
+var ErrInvalidIdentifier = errors.New("db: invalid identifier")
+
+var identRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QuoteIdent validates name (optionally schema-qualified: "schema.table")
+// and quotes each part with d.
+func QuoteIdent(d Dialect, name string) (string, error) {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return "", ErrInvalidIdentifier
+	}
+	for i, p := range parts {
+		if !identRe.MatchString(p) {
+			return "", ErrInvalidIdentifier
+		}
+		parts[i] = d.Quote(p)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// Rebind converts "?" placeholders in query to d's placeholders.
+// query must not contain literal question marks.
+func Rebind(d Dialect, query string) string {
+	if d.Placeholder(1) == "?" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(d.Placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Portable is the default dialect of Table: MySQL syntax ("?" placeholders,
+// backquoted identifiers), also accepted by SQLite, without row locking clauses.
+var Portable Dialect = portable{}
+
+type portable struct{ mysql }
+
+func (portable) SkipLocked() string { return "" }
+
+// Table is a table used by a SQL store.
+type Table struct {
+	Name    string  // optionally schema-qualified
+	Dialect Dialect // default Portable
+}
+
+func (t Table) dialect() Dialect {
+	return cmp.Or(t.Dialect, Portable)
+}
+
+// Quoted returns the validated, quoted table name (see QuoteIdent).
+func (t Table) Quoted() (string, error) {
+	return QuoteIdent(t.dialect(), t.Name)
+}
+
+// Query replaces {table} in q with the quoted table name and rebinds placeholders.
+func (t Table) Query(q string) (string, error) {
+	name, err := t.Quoted()
+	if err != nil {
+		return "", err
+	}
+	return Rebind(t.dialect(), strings.ReplaceAll(q, "{table}", name)), nil
+}
+
+// DefaultOwner returns "hostname-pid", the default owner of leases and locks.
+func DefaultOwner() string { return defaultOwner() }
+
+var defaultOwner = sync.OnceValue(func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+})
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/retry"
+)
+
+// Querier is implemented by *sql.DB, *sql.Tx and *sql.Conn.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+// ContextWithTx returns a context carrying tx, so that stores called with it
+// write in the caller's transaction (e.g. outbox.SQLStore.Save).
+func ContextWithTx(ctx context.Context, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// TxFromContext returns the transaction carried by ctx, if any.
+func TxFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok && tx != nil
+}
+
+// QuerierFrom returns the transaction carried by ctx, or db if there is none.
+func QuerierFrom(ctx context.Context, db *sql.DB) Querier {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return db
+}
+
+// TxOptions configures WithTx.
+type TxOptions struct {
+	Isolation sql.IsolationLevel
+	ReadOnly  bool
+	// Retry controls re-running the transaction (MaxAttempts 0: DefaultTxRetry; 1: no retry).
+	Retry retry.Config
+	// Retryable reports whether a failed attempt should be retried (default IsRetryable).
+	Retryable func(err error) bool
+}
+
+// DefaultTxRetry returns the retry config used by WithTx (3 attempts, 10ms initial, 50% jitter).
+func DefaultTxRetry() retry.Config {
+	return retry.Config{
+		MaxAttempts: 3,
+		Initial:     10 * time.Millisecond,
+		MaxBackoff:  time.Second,
+		Multiplier:  2,
+		Jitter:      0.5,
+	}
+}
+
+// WithTx runs fn in a transaction: it commits if fn returns nil and rolls back if
+// fn returns an error or panics (the panic is re-raised). When an attempt fails
+// with a serialization failure or deadlock, the whole transaction is run again,
+// so fn must not have side effects outside tx. Other errors are returned as is.
+func WithTx(ctx context.Context, db *sql.DB, opts TxOptions, fn func(tx *sql.Tx) error) error {
+	cfg := opts.Retry
+	if cfg.MaxAttempts == 0 {
+		cfg = DefaultTxRetry()
+	}
+	retryable := opts.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+	return retry.Do(ctx, cfg, func() error {
+		err := runTx(ctx, db, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}, fn)
+		if err != nil && !retryable(err) {
+			return retry.Permanent(err)
+		}
+		return err
+	})
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// IsRetryable reports whether err is a transient transaction conflict worth
+// retrying: SQLSTATE 40001 (serialization failure) or 40P01 (deadlock) from
+// drivers exposing SQLState() (pgx, lib/pq), MySQL errors 1213 (deadlock) and
+// 1205 (lock wait timeout), and SQLite busy/locked errors.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		switch state.SQLState() {
+		case "40001", "40P01":
+			return true
+		}
+	}
+	msg := err.Error()
+	for _, s := range []string{
+		"Error 1213", "Error 1205", // go-sql-driver/mysql
+		"database is locked", "database table is locked", "SQLITE_BUSY", "SQLITE_LOCKED", // sqlite drivers
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
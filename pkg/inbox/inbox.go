@@ -0,0 +1,223 @@
+// Package inbox implements the inbox pattern: the ID of each incoming message is
+// recorded in the same transaction as the handler's writes, so redelivered messages
+// change state only once (effectively-once on top of an at-least-once queue).
+package inbox
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/db"
+	"github.com/cosmos-toolkit/pkgs/pkg/outbox"
+	"github.com/cosmos-toolkit/pkgs/pkg/queue"
+)
+
+// KeyHeader is the header used as deduplication key by Handler: the event ID
+// generated by the outbox on Save, which is the same on every redelivery of an
+// event, unlike the broker's message ID.
+const KeyHeader = outbox.EventIDHeader
+
+// ErrDuplicate is returned by Process when the message was already processed.
+var ErrDuplicate = errors.New("inbox: duplicate message")
+
+// Store records processed messages.
+type Store interface {
+	// Process runs fn and records messageID for consumer atomically. ctx passed to
+	// fn carries the transaction (db.TxFromContext) for the handler's writes.
+	// Returns ErrDuplicate without running fn if the message was already recorded.
+	Process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) error
+}
+
+// HandlerOption configures Handler.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	key func(m *queue.Message) string
+}
+
+// WithKey sets the deduplication key of a message (default: the KeyHeader header,
+// else m.ID).
+func WithKey(key func(m *queue.Message) string) HandlerOption {
+	return func(c *handlerConfig) { c.key = key }
+}
+
+// Handler wraps a queue.Consumer handler so each message runs through store.Process.
+// Duplicates are skipped and acked (nil error); handler errors roll back the
+// transaction and are returned so the message is redelivered.
+func Handler(store Store, consumer string, h func(ctx context.Context, m *queue.Message) error, opts ...HandlerOption) func(ctx context.Context, m *queue.Message) error {
+	cfg := handlerConfig{key: defaultKey}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	return func(ctx context.Context, m *queue.Message) error {
+		err := store.Process(ctx, consumer, cfg.key(m), func(ctx context.Context) error {
+			return h(ctx, m)
+		})
+		if errors.Is(err, ErrDuplicate) {
+			return nil
+		}
+		return err
+	}
+}
+
+// defaultKey returns the outbox event ID header, falling back to the message ID.
+func defaultKey(m *queue.Message) string {
+	if id := m.Headers[KeyHeader]; id != "" {
+		return id
+	}
+	return m.ID
+}
+
+// SQLStore implements Store with inbox table
+// (id, consumer, message_id, processed_at; unique on consumer, message_id). See CreateSchema.
+type SQLStore struct {
+	DB      *sql.DB
+	Table   string     // default "inbox"; optionally schema-qualified
+	Dialect db.Dialect // default db.Portable
+}
+
+func (s *SQLStore) table() db.Table {
+	return db.Table{Name: cmp.Or(s.Table, "inbox"), Dialect: s.Dialect}
+}
+
+// Process implements Store. If ctx already carries a transaction (db.ContextWithTx),
+// the message is recorded in it and the caller commits; otherwise Process begins
+// and commits its own. Two concurrent deliveries of the same message make one of
+// them fail on the unique key; it is rolled back and is a duplicate on redelivery.
+func (s *SQLStore) Process(ctx context.Context, consumer, messageID string, fn func(ctx context.Context) error) error {
+	if tx, ok := db.TxFromContext(ctx); ok {
+		if err := s.record(ctx, tx, consumer, messageID); err != nil {
+			return err
+		}
+		return fn(ctx)
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.record(ctx, tx, consumer, messageID); err != nil {
+		return err
+	}
+	if err := fn(db.ContextWithTx(ctx, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// record inserts the message unless already present.
+func (s *SQLStore) record(ctx context.Context, q db.Querier, consumer, messageID string) error {
+	sel, err := s.table().Query(`SELECT 1 FROM {table} WHERE consumer = ? AND message_id = ?`)
+	if err != nil {
+		return err
+	}
+	var one int
+	err = q.QueryRowContext(ctx, sel, consumer, messageID).Scan(&one)
+	if err == nil {
+		return ErrDuplicate
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	ins, err := s.table().Query(`INSERT INTO {table} (consumer, message_id, processed_at) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	_, err = q.ExecContext(ctx, ins, consumer, messageID, time.Now())
+	return err
+}
+
+// DeleteProcessed removes up to limit messages processed before the given time and
+// returns how many were removed. Messages are deduplicated only while recorded,
+// so keep them longer than the queue may redeliver.
+func (s *SQLStore) DeleteProcessed(ctx context.Context, before time.Time, limit int) (int64, error) {
+	sel, err := s.table().Query(`SELECT id FROM {table} WHERE processed_at < ? ORDER BY id LIMIT ?`)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := s.DB.QueryContext(ctx, sel, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	var ids []any
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	del, err := s.table().Query(`DELETE FROM {table} WHERE id IN (` + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + `)`)
+	if err != nil {
+		return 0, err
+	}
+	res, err := s.DB.ExecContext(ctx, del, ids...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// schemas holds the inbox table DDL per dialect name; {table} and {unique_idx} are replaced.
+var schemas = map[string][]string{
+	"postgres": {
+		`CREATE TABLE IF NOT EXISTS {table} (
+	id           BIGSERIAL PRIMARY KEY,
+	consumer     TEXT NOT NULL,
+	message_id   TEXT NOT NULL,
+	processed_at TIMESTAMPTZ NOT NULL
+)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS {unique_idx} ON {table} (consumer, message_id)`,
+	},
+	"mysql": {
+		`CREATE TABLE IF NOT EXISTS {table} (
+	id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+	consumer     VARCHAR(255) NOT NULL,
+	message_id   VARCHAR(255) NOT NULL,
+	processed_at DATETIME(6) NOT NULL,
+	UNIQUE INDEX {unique_idx} (consumer, message_id)
+)`,
+	},
+	"sqlite": {
+		`CREATE TABLE IF NOT EXISTS {table} (
+	id           INTEGER PRIMARY KEY AUTOINCREMENT,
+	consumer     TEXT NOT NULL,
+	message_id   TEXT NOT NULL,
+	processed_at TIMESTAMP NOT NULL
+)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS {unique_idx} ON {table} (consumer, message_id)`,
+	},
+}
+
+// CreateSchema creates the inbox table and its unique index for s.Dialect, which must be set.
+func (s *SQLStore) CreateSchema(ctx context.Context) error {
+	if s.Dialect == nil {
+		return errors.New("inbox: CreateSchema requires SQLStore.Dialect")
+	}
+	stmts, ok := schemas[s.Dialect.Name()]
+	if !ok {
+		return fmt.Errorf("inbox: no schema for dialect %q", s.Dialect.Name())
+	}
+	name := s.table().Name
+	idx := s.Dialect.Quote(name[strings.LastIndex(name, ".")+1:] + "_consumer_message_idx")
+	for _, stmt := range stmts {
+		q, err := s.table().Query(strings.ReplaceAll(stmt, "{unique_idx}", idx))
+		if err != nil {
+			return err
+		}
+		if _, err := s.DB.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
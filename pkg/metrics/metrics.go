@@ -2,6 +2,8 @@
 package metrics
 
 import (
+	"errors"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -30,3 +32,66 @@ func Histogram(name, help string, buckets []float64) prometheus.Histogram {
 func DefaultLatencyBuckets() []float64 {
 	return []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
 }
+
+// CounterWith creates a counter registered in reg (default registry if nil).
+// An identical collector already registered is reused instead of panicking.
+func CounterWith(reg prometheus.Registerer, name, help string) prometheus.Counter {
+	return register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}))
+}
+
+// GaugeWith creates a gauge registered in reg (default registry if nil).
+func GaugeWith(reg prometheus.Registerer, name, help string) prometheus.Gauge {
+	return register(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: name,
+		Help: help,
+	}))
+}
+
+// CounterVecWith creates a labelled counter registered in reg (default registry if nil).
+// An identical collector already registered is reused instead of panicking.
+func CounterVecWith(reg prometheus.Registerer, name, help string, labels ...string) *prometheus.CounterVec {
+	return register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: name,
+		Help: help,
+	}, labels))
+}
+
+// GaugeVecWith creates a labelled gauge registered in reg (default registry if nil).
+func GaugeVecWith(reg prometheus.Registerer, name, help string, labels ...string) *prometheus.GaugeVec {
+	return register(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: name,
+		Help: help,
+	}, labels))
+}
+
+// HistogramVecWith creates a labelled histogram registered in reg (default registry if nil).
+// Uses DefaultLatencyBuckets if buckets is empty.
+func HistogramVecWith(reg prometheus.Registerer, name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets()
+	}
+	return register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    name,
+		Help:    help,
+		Buckets: buckets,
+	}, labels))
+}
+
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
@@ -0,0 +1,137 @@
+// Package outbox: backlog metrics, publish metrics and tracing for the Processor.
+
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/contextx"
+	"github.com/cosmos-toolkit/pkgs/pkg/metrics"
+	"github.com/cosmos-toolkit/pkgs/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Backlogger is implemented by stores that can report the unpublished backlog.
+type Backlogger interface {
+	// Backlog returns the number of events waiting to be published (excluding dead
+	// events) and the creation time of the oldest, or the zero time if none.
+	Backlog(ctx context.Context) (int64, time.Time, error)
+}
+
+// InstrumentedConfig configures processor instrumentation.
+type InstrumentedConfig struct {
+	TracerName string
+	MetricName string                // metric name prefix
+	Registerer prometheus.Registerer // default: prometheus.DefaultRegisterer
+}
+
+// DefaultInstrumentedConfig returns default instrumentation config.
+func DefaultInstrumentedConfig() InstrumentedConfig {
+	return InstrumentedConfig{
+		TracerName: "outbox",
+		MetricName: "outbox",
+	}
+}
+
+// WithInstrumentation records a publish span per event, linked to the trace that
+// saved it, and metrics: publish latency, published and failed events by topic,
+// and, for a Backlogger store, the pending count and the oldest pending age
+// (refreshed once per processor interval).
+func WithInstrumentation(cfg InstrumentedConfig) Option {
+	return func(p *Processor) {
+		reg, name := cfg.Registerer, cfg.MetricName
+		p.inst = &instrumentation{
+			tracerName: cfg.TracerName,
+			duration:   metrics.HistogramVecWith(reg, name+"_publish_duration_seconds", "Outbox publish latency in seconds", nil, "topic"),
+			published:  metrics.CounterVecWith(reg, name+"_published_total", "Total outbox events published", "topic"),
+			failures:   metrics.CounterVecWith(reg, name+"_publish_failures_total", "Total failed outbox publish attempts", "topic"),
+			pending:    metrics.GaugeWith(reg, name+"_pending", "Outbox events waiting to be published"),
+			oldest:     metrics.GaugeWith(reg, name+"_oldest_pending_age_seconds", "Age of the oldest outbox event waiting to be published"),
+		}
+	}
+}
+
+type instrumentation struct {
+	tracerName string
+	duration   *prometheus.HistogramVec
+	published  *prometheus.CounterVec
+	failures   *prometheus.CounterVec
+	pending    prometheus.Gauge
+	oldest     prometheus.Gauge
+}
+
+// publish publishes e; a nil instrumentation just publishes it.
+func (i *instrumentation) publish(ctx context.Context, pub Publisher, e *Event) error {
+	if i == nil {
+		return pub.Publish(ctx, e.Topic, e.Payload, e.Metadata)
+	}
+	var opts []trace.SpanStartOption
+	if origin := trace.SpanContextFromContext(tracing.Extract(context.Background(), e.Metadata)); origin.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: origin}))
+	}
+	start := time.Now()
+	ctx, span := tracing.StartSpan(ctx, i.tracerName, "publish", opts...)
+	span.SetAttributes(
+		attribute.String("topic", e.Topic),
+		attribute.Int64("event_id", e.ID),
+		attribute.Int("attempt", e.Attempts+1),
+	)
+	defer span.End()
+
+	err := pub.Publish(ctx, e.Topic, e.Payload, e.Metadata)
+	i.duration.WithLabelValues(e.Topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.Bool("error", true))
+		i.failures.WithLabelValues(e.Topic).Inc()
+		return err
+	}
+	i.published.WithLabelValues(e.Topic).Inc()
+	return nil
+}
+
+// backlog updates the backlog gauges if the store reports them.
+func (i *instrumentation) backlog(ctx context.Context, store Store) {
+	b, ok := store.(Backlogger)
+	if i == nil || !ok {
+		return
+	}
+	n, oldest, err := b.Backlog(ctx)
+	if err != nil {
+		return
+	}
+	i.pending.Set(float64(n))
+	if oldest.IsZero() {
+		i.oldest.Set(0)
+		return
+	}
+	i.oldest.Set(time.Since(oldest).Seconds())
+}
+
+// contextMetadata returns metadata plus the trace context (traceparent, tracestate)
+// and contextx IDs of ctx. Keys already in metadata are kept.
+func contextMetadata(ctx context.Context, metadata map[string]string) map[string]string {
+	out := make(map[string]string, len(metadata)+4)
+	tracing.Inject(ctx, out)
+	for k, v := range map[string]string{
+		"trace_id":   contextx.TraceID(ctx),
+		"request_id": contextx.RequestID(ctx),
+		"user_id":    contextx.UserID(ctx),
+		"tenant_id":  contextx.Tenant(ctx),
+	} {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return metadata
+	}
+	for k, v := range metadata {
+		out[k] = v
+	}
+	return out
+}
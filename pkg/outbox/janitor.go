@@ -0,0 +1,131 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cleaner is implemented by stores that can purge published events.
+type Cleaner interface {
+	// DeletePublished removes (or archives) up to limit events published before
+	// the given time and returns how many were removed.
+	DeletePublished(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// Defaults for the Janitor.
+const (
+	DefaultRetention       = 7 * 24 * time.Hour
+	DefaultCleanupBatch    = 1000
+	DefaultCleanupInterval = time.Hour
+)
+
+// JanitorConfig configures retention of published events.
+type JanitorConfig struct {
+	Retention  time.Duration         // published events older than this are removed
+	BatchSize  int                   // rows per delete statement
+	Interval   time.Duration         // time between cleanup passes
+	MetricName string                // metric name prefix
+	Registerer prometheus.Registerer // default: prometheus.DefaultRegisterer
+}
+
+// DefaultJanitorConfig returns a 7 day retention, cleaned hourly in batches of 1000.
+func DefaultJanitorConfig() JanitorConfig {
+	return JanitorConfig{
+		Retention:  DefaultRetention,
+		BatchSize:  DefaultCleanupBatch,
+		Interval:   DefaultCleanupInterval,
+		MetricName: "outbox_cleanup",
+	}
+}
+
+// Janitor periodically removes published events past their retention in
+// bounded batches, so the outbox table does not grow forever.
+type Janitor struct {
+	cleaner Cleaner
+	cfg     JanitorConfig
+	deleted prometheus.Counter
+	errors  prometheus.Counter
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewJanitor creates a janitor; zero config fields use the defaults.
+func NewJanitor(cleaner Cleaner, cfg JanitorConfig) *Janitor {
+	def := DefaultJanitorConfig()
+	if cfg.Retention <= 0 {
+		cfg.Retention = def.Retention
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = def.BatchSize
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = def.Interval
+	}
+	if cfg.MetricName == "" {
+		cfg.MetricName = def.MetricName
+	}
+	reg, name := cfg.Registerer, cfg.MetricName
+	return &Janitor{
+		cleaner: cleaner,
+		cfg:     cfg,
+		deleted: metrics.CounterWith(reg, name+"_deleted_total", "Total published outbox events removed"),
+		errors:  metrics.CounterWith(reg, name+"_errors_total", "Total failed outbox cleanup passes"),
+		stop:    make(chan struct{}),
+	}
+}
+
+// Start runs a cleanup pass every Interval in the background. Use Stop() to stop.
+func (j *Janitor) Start(ctx context.Context) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		tick := time.NewTicker(j.cfg.Interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-j.stop:
+				return
+			case <-tick.C:
+				_, _ = j.Clean(ctx)
+			}
+		}
+	}()
+}
+
+// Stop signals stop and waits for the current pass to finish its batch.
+func (j *Janitor) Stop() {
+	close(j.stop)
+	j.wg.Wait()
+}
+
+// Clean runs one pass, removing batches until fewer than BatchSize rows are left,
+// and returns the number of rows removed.
+func (j *Janitor) Clean(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-j.cfg.Retention)
+	var total int64
+	for {
+		n, err := j.cleaner.DeletePublished(ctx, before, j.cfg.BatchSize)
+		total += n
+		j.deleted.Add(float64(n))
+		if err != nil {
+			j.errors.Inc()
+			return total, err
+		}
+		if n < int64(j.cfg.BatchSize) {
+			return total, nil
+		}
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-j.stop:
+			return total, nil
+		default:
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store, Claimer, RetryStore, Cleaner and Backlogger in
+// memory with the same semantics as SQLStore (tests and single-process apps).
+// Safe for concurrent use.
+type MemoryStore struct {
+	mu     sync.Mutex
+	nextID int64
+	events []*memoryEvent // in id order
+}
+
+type memoryEvent struct {
+	Event
+	publishedAt time.Time
+	nextAttempt time.Time
+	failedAt    time.Time
+	leaseOwner  string
+	leaseUntil  time.Time
+}
+
+// NewMemoryStore creates an in-memory outbox store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Save implements Store. Like SQLStore, an event ID and the trace context,
+// contextx IDs and ordering key of ctx are recorded.
+func (s *MemoryStore) Save(ctx context.Context, topic string, payload []byte, metadata map[string]string) error {
+	meta := eventMetadata(ctx, metadata)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.events = append(s.events, &memoryEvent{Event: Event{
+		ID:          s.nextID,
+		Topic:       topic,
+		Payload:     append([]byte(nil), payload...),
+		Metadata:    meta,
+		CreatedAt:   time.Now(),
+		OrderingKey: orderingKey(ctx),
+	}})
+	return nil
+}
+
+// publishable returns events ready to publish in id order, applying the same
+// filters as SQLStore; s.mu must be held.
+func (s *MemoryStore) publishable(now time.Time, limit int, leased bool) []*memoryEvent {
+	var out []*memoryEvent
+	held := make(map[string]bool) // keys with an earlier unpublished event
+	for _, e := range s.events {
+		if !e.publishedAt.IsZero() {
+			continue
+		}
+		earlier := e.OrderingKey != "" && held[e.OrderingKey]
+		if e.OrderingKey != "" {
+			held[e.OrderingKey] = true
+		}
+		if earlier || !e.failedAt.IsZero() || e.nextAttempt.After(now) {
+			continue
+		}
+		if leased && !e.leaseUntil.IsZero() && !e.leaseUntil.Before(now) {
+			continue
+		}
+		if len(out) == limit {
+			break
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// copyEvent returns a copy safe to hand to callers.
+func copyEvent(e *memoryEvent) *Event {
+	c := e.Event
+	c.Payload = append([]byte(nil), e.Payload...)
+	c.Metadata = make(map[string]string, len(e.Metadata))
+	for k, v := range e.Metadata {
+		c.Metadata[k] = v
+	}
+	return &c
+}
+
+// Pending implements Store.
+func (s *MemoryStore) Pending(ctx context.Context, limit int) ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Event
+	for _, e := range s.publishable(time.Now(), limit, false) {
+		out = append(out, copyEvent(e))
+	}
+	return out, nil
+}
+
+// Claim implements Claimer.
+func (s *MemoryStore) Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var out []*Event
+	for _, e := range s.publishable(now, limit, true) {
+		e.leaseOwner, e.leaseUntil = owner, now.Add(lease)
+		out = append(out, copyEvent(e))
+	}
+	return out, nil
+}
+
+// find returns the event with id; s.mu must be held.
+func (s *MemoryStore) find(id int64) *memoryEvent {
+	for _, e := range s.events {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// MarkPublished implements Store.
+func (s *MemoryStore) MarkPublished(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e := s.find(id); e != nil {
+		e.publishedAt = time.Now()
+		e.leaseOwner, e.leaseUntil = "", time.Time{}
+	}
+	return nil
+}
+
+// MarkFailed implements RetryStore.
+func (s *MemoryStore) MarkFailed(ctx context.Context, id int64, lastErr string, nextAttempt time.Time, dead bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.find(id)
+	if e == nil {
+		return nil
+	}
+	e.Attempts++
+	e.LastError, e.nextAttempt = lastErr, nextAttempt
+	e.leaseOwner, e.leaseUntil = "", time.Time{}
+	if dead {
+		e.failedAt = time.Now()
+	}
+	return nil
+}
+
+// Failed implements RetryStore.
+func (s *MemoryStore) Failed(ctx context.Context, limit int) ([]*Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Event
+	for _, e := range s.events {
+		if len(out) == limit {
+			break
+		}
+		if e.publishedAt.IsZero() && !e.failedAt.IsZero() {
+			out = append(out, copyEvent(e))
+		}
+	}
+	return out, nil
+}
+
+// Requeue implements RetryStore.
+func (s *MemoryStore) Requeue(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.find(id)
+	if e == nil || e.failedAt.IsZero() {
+		return ErrEventNotFound
+	}
+	e.failedAt, e.nextAttempt, e.Attempts = time.Time{}, time.Time{}, 0
+	return nil
+}
+
+// DeletePublished implements Cleaner.
+func (s *MemoryStore) DeletePublished(ctx context.Context, before time.Time, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	kept := s.events[:0]
+	for _, e := range s.events {
+		if n < int64(limit) && !e.publishedAt.IsZero() && e.publishedAt.Before(before) {
+			n++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	clear(s.events[len(kept):])
+	s.events = kept
+	return n, nil
+}
+
+// Backlog implements Backlogger.
+func (s *MemoryStore) Backlog(ctx context.Context) (int64, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	var oldest time.Time
+	for _, e := range s.events {
+		if !e.publishedAt.IsZero() || !e.failedAt.IsZero() {
+			continue
+		}
+		if n == 0 {
+			oldest = e.CreatedAt
+		}
+		n++
+	}
+	return n, oldest, nil
+}
@@ -0,0 +1,12 @@
+package outbox_test
+
+import (
+	"testing"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/outbox"
+	"github.com/cosmos-toolkit/pkgs/pkg/outbox/outboxtest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	outboxtest.TestStore(t, func(t *testing.T) outbox.Store { return outbox.NewMemoryStore() })
+}
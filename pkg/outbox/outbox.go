@@ -3,11 +3,19 @@
 package outbox
 
 import (
+	"cmp"
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/db"
+	"github.com/cosmos-toolkit/pkgs/pkg/retry"
 )
 
 // Store persists and reads outbox events.
@@ -17,6 +25,27 @@ type Store interface {
 	MarkPublished(ctx context.Context, id int64) error
 }
 
+// Claimer is implemented by stores that lease events to one processor at a time,
+// so that several replicas can run a Processor without publishing duplicates.
+type Claimer interface {
+	// Claim leases up to limit unpublished events to owner until lease elapses.
+	Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Event, error)
+}
+
+// RetryStore is implemented by stores that track publish failures per event.
+type RetryStore interface {
+	// MarkFailed records a failed attempt and releases the event's lease. The event is
+	// retried after nextAttempt, or never again if dead (until Requeue).
+	MarkFailed(ctx context.Context, id int64, lastErr string, nextAttempt time.Time, dead bool) error
+	// Failed returns dead events, oldest first.
+	Failed(ctx context.Context, limit int) ([]*Event, error)
+	// Requeue makes a dead event pending again with its attempts reset.
+	Requeue(ctx context.Context, id int64) error
+}
+
+// ErrEventNotFound is returned by Requeue when no dead event has the given id.
+var ErrEventNotFound = errors.New("outbox: event not found")
+
 // Publisher publishes a message (e.g. queue, broker).
 type Publisher interface {
 	Publish(ctx context.Context, topic string, body []byte, headers map[string]string) error
@@ -29,26 +58,161 @@ type Event struct {
 	Payload   []byte
 	Metadata  map[string]string
 	CreatedAt time.Time
+	Attempts  int    // failed publish attempts (RetryStore)
+	LastError string // last publish error (RetryStore)
+	// OrderingKey, if set, orders publishing among events with the same key (see WithOrderingKey).
+	OrderingKey string
+}
+
+// EventIDHeader is the metadata key carrying a random UUID generated by Save.
+// It is unique across producers and outbox tables and stable across redeliveries
+// of the same event, so consumers can deduplicate on it (inbox.Handler does by default).
+const EventIDHeader = "outbox_event_id"
+
+// eventMetadata returns the metadata recorded by Save: metadata plus the context
+// metadata of ctx (see contextMetadata) and a new EventIDHeader unless already set.
+func eventMetadata(ctx context.Context, metadata map[string]string) map[string]string {
+	md := contextMetadata(ctx, metadata)
+	out := make(map[string]string, len(md)+1)
+	out[EventIDHeader] = newEventID()
+	for k, v := range md {
+		out[k] = v
+	}
+	return out
+}
+
+// newEventID returns a random (version 4) UUID.
+func newEventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type orderingKeyCtx struct{}
+
+// WithOrderingKey returns a context whose saved events carry key (e.g. an aggregate ID).
+// Events with the same key are published in id order: while one is unpublished
+// (failing, backing off or dead), later events with that key wait. Other keys keep flowing.
+func WithOrderingKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, orderingKeyCtx{}, key)
+}
+
+func orderingKey(ctx context.Context) string {
+	key, _ := ctx.Value(orderingKeyCtx{}).(string)
+	return key
 }
 
 // DefaultTTL is the default TTL for processing (avoid reprocessing indefinitely).
 const DefaultTTL = 24 * time.Hour
 
+// Defaults for claiming stores.
+const (
+	DefaultBatchSize = 100
+	DefaultLease     = time.Minute
+)
+
+// DefaultRetryConfig returns the backoff used with a RetryStore (10 attempts, 10s initial, 1h max).
+func DefaultRetryConfig() retry.Config {
+	return retry.Config{
+		MaxAttempts: 10,
+		Initial:     10 * time.Second,
+		MaxBackoff:  time.Hour,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
 // Processor processes pending events and publishes them.
 type Processor struct {
 	store     Store
 	publisher Publisher
 	interval  time.Duration
+	batchSize int
+	owner     string
+	lease     time.Duration
+	retry     retry.Config
+	ttl       time.Duration
+	inst      *instrumentation
+	wake      chan struct{}   // Notify
+	wakeup    <-chan struct{} // WithWakeup
 	stop      chan struct{}
 	wg        sync.WaitGroup
 }
 
-// NewProcessor creates an outbox processor.
-func NewProcessor(store Store, publisher Publisher, interval time.Duration) *Processor {
+// Option configures the Processor.
+type Option func(*Processor)
+
+// WithBatchSize sets how many events are read per round (default DefaultBatchSize).
+func WithBatchSize(n int) Option {
+	return func(p *Processor) {
+		if n > 0 {
+			p.batchSize = n
+		}
+	}
+}
+
+// WithOwner sets the lease owner used with a Claimer store (default db.DefaultOwner).
+func WithOwner(owner string) Option {
+	return func(p *Processor) { p.owner = owner }
+}
+
+// WithLease sets how long claimed events stay reserved (default DefaultLease).
+// It must exceed the time to publish a batch.
+func WithLease(d time.Duration) Option {
+	return func(p *Processor) {
+		if d > 0 {
+			p.lease = d
+		}
+	}
+}
+
+// WithRetry sets the backoff and max attempts for failed events (default DefaultRetryConfig).
+// Requires a RetryStore; otherwise failed events are retried every round.
+func WithRetry(cfg retry.Config) Option {
+	return func(p *Processor) { p.retry = cfg }
+}
+
+// WithTTL marks events older than d as dead after a failed attempt (default DefaultTTL).
+func WithTTL(d time.Duration) Option {
+	return func(p *Processor) {
+		if d > 0 {
+			p.ttl = d
+		}
+	}
+}
+
+// WithWakeup makes the processor drain the outbox whenever ch receives, e.g. on a
+// Postgres notification (see SQLStore.CreateNotifyTrigger) forwarded by the driver's listener.
+// The interval ticker stays as a fallback; once ch is closed, only the ticker and
+// Notify wake the processor.
+func WithWakeup(ch <-chan struct{}) Option {
+	return func(p *Processor) { p.wakeup = ch }
+}
+
+// NewProcessor creates an outbox processor. If store implements Claimer,
+// events are claimed before publishing, so the processor can run on several replicas.
+func NewProcessor(store Store, publisher Publisher, interval time.Duration, opts ...Option) *Processor {
 	if interval <= 0 {
 		interval = 10 * time.Second
 	}
-	return &Processor{store: store, publisher: publisher, interval: interval, stop: make(chan struct{})}
+	p := &Processor{
+		store:     store,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: DefaultBatchSize,
+		owner:     db.DefaultOwner(),
+		lease:     DefaultLease,
+		retry:     DefaultRetryConfig(),
+		ttl:       DefaultTTL,
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Start starts processing in the background. Use Stop() to stop.
@@ -58,6 +222,7 @@ func (p *Processor) Start(ctx context.Context) {
 		defer p.wg.Done()
 		tick := time.NewTicker(p.interval)
 		defer tick.Stop()
+		wakeup := p.wakeup
 		for {
 			select {
 			case <-ctx.Done():
@@ -65,59 +230,315 @@ func (p *Processor) Start(ctx context.Context) {
 			case <-p.stop:
 				return
 			case <-tick.C:
-				_ = p.process(ctx)
+				p.inst.backlog(ctx, p.store) // once per interval, not per batch
+			case <-p.wake:
+			case _, ok := <-wakeup:
+				if !ok {
+					wakeup = nil // closed: fall back to the ticker
+					continue
+				}
 			}
+			p.drain(ctx)
 		}
 	}()
 }
 
+// Notify wakes the processor to publish right away instead of waiting for the
+// next tick; call it after the transaction that saved events commits. Never blocks.
+func (p *Processor) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// drain processes batches until a round publishes nothing.
+func (p *Processor) drain(ctx context.Context) {
+	for {
+		n, err := p.process(ctx)
+		if err != nil || n == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.stop:
+			return
+		default:
+		}
+	}
+}
+
 // Stop signals stop and waits for completion.
 func (p *Processor) Stop() {
 	close(p.stop)
 	p.wg.Wait()
 }
 
-func (p *Processor) process(ctx context.Context) error {
-	events, err := p.store.Pending(ctx, 100)
+// process publishes one batch and returns how many events were published.
+func (p *Processor) process(ctx context.Context) (int, error) {
+	var events []*Event
+	var err error
+	if c, ok := p.store.(Claimer); ok {
+		events, err = c.Claim(ctx, p.owner, p.batchSize, p.lease)
+	} else {
+		events, err = p.store.Pending(ctx, p.batchSize)
+	}
 	if err != nil || len(events) == 0 {
-		return err
+		return 0, err
 	}
+	published := 0
+	blocked := make(map[string]bool) // ordering keys with a failed event in this batch
 	for _, e := range events {
-		if err := p.publisher.Publish(ctx, e.Topic, e.Payload, e.Metadata); err != nil {
+		if e.OrderingKey != "" && blocked[e.OrderingKey] {
+			continue
+		}
+		if err := p.inst.publish(ctx, p.publisher, e); err != nil {
+			p.fail(ctx, e, err)
+			if e.OrderingKey != "" {
+				blocked[e.OrderingKey] = true
+			}
 			continue
 		}
-		_ = p.store.MarkPublished(ctx, e.ID)
+		if p.store.MarkPublished(ctx, e.ID) == nil {
+			published++
+		}
 	}
-	return nil
+	return published, nil
+}
+
+// fail records a failed attempt with backoff; the event is dead after max attempts or TTL.
+func (p *Processor) fail(ctx context.Context, e *Event, err error) {
+	rs, ok := p.store.(RetryStore)
+	if !ok {
+		return
+	}
+	attempts := e.Attempts + 1
+	dead := attempts >= p.retry.MaxAttempts || time.Since(e.CreatedAt) > p.ttl
+	next := time.Now().Add(retry.Backoff(p.retry, attempts))
+	_ = rs.MarkFailed(ctx, e.ID, err.Error(), next, dead)
 }
 
-// SQLStore implements Store with outbox table (id, topic, payload, metadata_json, created_at, published_at).
+// SQLStore implements Store, Claimer, RetryStore, Cleaner and Backlogger with outbox table
+// (id, topic, payload, metadata_json, created_at, published_at, lease_owner, lease_until,
+// attempts, last_error, next_attempt_at, failed_at, ordering_key). See CreateSchema.
 type SQLStore struct {
-	DB    *sql.DB
-	Table string
+	DB      *sql.DB
+	Table   string     // default "outbox"; optionally schema-qualified
+	Dialect db.Dialect // default db.Portable
+	// ArchiveTable, if set, receives published events before DeletePublished removes them
+	// (id, topic, payload, metadata_json, created_at, published_at).
+	ArchiveTable string
 }
 
-// Save inserts an event into the outbox table.
+func (s *SQLStore) dialect() db.Dialect {
+	return cmp.Or(s.Dialect, db.Portable)
+}
+
+func (s *SQLStore) table() db.Table {
+	return db.Table{Name: cmp.Or(s.Table, "outbox"), Dialect: s.Dialect}
+}
+
+func (s *SQLStore) quote(name string) (string, error) {
+	return db.Table{Name: name, Dialect: s.Dialect}.Quoted()
+}
+
+// query replaces {table} (and {archive}) with the validated, quoted table names and rebinds placeholders.
+func (s *SQLStore) query(q string) (string, error) {
+	if strings.Contains(q, "{archive}") {
+		archive, err := s.quote(s.ArchiveTable)
+		if err != nil {
+			return "", err
+		}
+		q = strings.ReplaceAll(q, "{archive}", archive)
+	}
+	return s.table().Query(q)
+}
+
+// Save inserts an event into the outbox table. If ctx carries a transaction
+// (db.ContextWithTx), the event is written in it and commits or rolls back with
+// the caller's business writes. An event ID (EventIDHeader) and the trace context
+// and contextx IDs of ctx are added to metadata, and its ordering key (WithOrderingKey) is stored.
 func (s *SQLStore) Save(ctx context.Context, topic string, payload []byte, metadata map[string]string) error {
-	table := s.Table
-	if table == "" {
-		table = "outbox"
-	}
-	metaJSON, _ := json.Marshal(metadata)
-	_, err := s.DB.ExecContext(ctx,
-		`INSERT INTO `+table+` (topic, payload, metadata_json, created_at) VALUES (?, ?, ?, ?)`,
-		topic, payload, metaJSON, time.Now())
+	_, err := s.insert(ctx, db.QuerierFrom(ctx, s.DB), topic, payload, metadata)
 	return err
 }
 
-// Pending returns events not yet published (published_at IS NULL).
+// SaveTx inserts an event in the caller's transaction and returns its id.
+func (s *SQLStore) SaveTx(ctx context.Context, tx *sql.Tx, topic string, payload []byte, metadata map[string]string) (int64, error) {
+	return s.insert(ctx, tx, topic, payload, metadata)
+}
+
+// insert uses RETURNING when the dialect supports it, LastInsertId otherwise.
+func (s *SQLStore) insert(ctx context.Context, q db.Querier, topic string, payload []byte, metadata map[string]string) (int64, error) {
+	metaJSON, _ := json.Marshal(eventMetadata(ctx, metadata))
+	var key any
+	if k := orderingKey(ctx); k != "" {
+		key = k
+	}
+	args := []any{topic, payload, string(metaJSON), time.Now(), key}
+	stmt := `INSERT INTO {table} (topic, payload, metadata_json, created_at, ordering_key) VALUES (?, ?, ?, ?, ?)`
+	if s.dialect().Returning() {
+		query, err := s.query(stmt + ` RETURNING id`)
+		if err != nil {
+			return 0, err
+		}
+		var id int64
+		return id, q.QueryRowContext(ctx, query, args...).Scan(&id)
+	}
+	query, err := s.query(stmt)
+	if err != nil {
+		return 0, err
+	}
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// eventColumns are the columns read by scanEvents.
+const eventColumns = `id, topic, payload, metadata_json, created_at, attempts, last_error, ordering_key`
+
+// publishable filters unpublished, not dead events whose backoff elapsed (one ? for now)
+// and that have no earlier unpublished event with the same ordering key. The table is aliased e.
+const publishable = `published_at IS NULL AND failed_at IS NULL AND (next_attempt_at IS NULL OR next_attempt_at <= ?)` +
+	` AND (e.ordering_key IS NULL OR NOT EXISTS (SELECT 1 FROM {table} p` +
+	` WHERE p.ordering_key = e.ordering_key AND p.id < e.id AND p.published_at IS NULL))`
+
+// Pending returns events ready to publish: not published, not dead and past their backoff.
 func (s *SQLStore) Pending(ctx context.Context, limit int) ([]*Event, error) {
-	table := s.Table
-	if table == "" {
-		table = "outbox"
+	query, err := s.query(`SELECT ` + eventColumns + ` FROM {table} e WHERE ` + publishable + ` ORDER BY id LIMIT ?`)
+	if err != nil {
+		return nil, err
 	}
-	rows, err := s.DB.QueryContext(ctx,
-		`SELECT id, topic, payload, metadata_json, created_at FROM `+table+` WHERE published_at IS NULL ORDER BY id LIMIT ?`, limit)
+	return scanEvents(s.DB.QueryContext(ctx, query, time.Now(), limit))
+}
+
+// Backlog implements Backlogger.
+func (s *SQLStore) Backlog(ctx context.Context) (int64, time.Time, error) {
+	count, err := s.query(`SELECT COUNT(*) FROM {table} WHERE published_at IS NULL AND failed_at IS NULL`)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var n int64
+	if err := s.DB.QueryRowContext(ctx, count).Scan(&n); err != nil || n == 0 {
+		return n, time.Time{}, err
+	}
+	oldest, err := s.query(`SELECT created_at FROM {table} WHERE published_at IS NULL AND failed_at IS NULL ORDER BY id LIMIT 1`)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var t time.Time
+	err = s.DB.QueryRowContext(ctx, oldest).Scan(&t)
+	if err == sql.ErrNoRows {
+		err = nil
+	}
+	return n, t, err
+}
+
+// MarkFailed implements RetryStore.
+func (s *SQLStore) MarkFailed(ctx context.Context, id int64, lastErr string, nextAttempt time.Time, dead bool) error {
+	query, err := s.query(`UPDATE {table} SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?, failed_at = ?,` +
+		` lease_owner = NULL, lease_until = NULL WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	var failedAt any
+	if dead {
+		failedAt = time.Now()
+	}
+	_, err = s.DB.ExecContext(ctx, query, lastErr, nextAttempt, failedAt, id)
+	return err
+}
+
+// Failed implements RetryStore.
+func (s *SQLStore) Failed(ctx context.Context, limit int) ([]*Event, error) {
+	query, err := s.query(`SELECT ` + eventColumns + ` FROM {table} WHERE published_at IS NULL AND failed_at IS NOT NULL ORDER BY id LIMIT ?`)
+	if err != nil {
+		return nil, err
+	}
+	return scanEvents(s.DB.QueryContext(ctx, query, limit))
+}
+
+// Requeue implements RetryStore.
+func (s *SQLStore) Requeue(ctx context.Context, id int64) error {
+	query, err := s.query(`UPDATE {table} SET failed_at = NULL, next_attempt_at = NULL, attempts = 0 WHERE id = ? AND failed_at IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	res, err := s.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+// DeletePublished implements Cleaner. It selects up to limit ids published before
+// the given time, archives them if ArchiveTable is set, and deletes them in one
+// transaction. An index on published_at keeps this cheap on large tables.
+func (s *SQLStore) DeletePublished(ctx context.Context, before time.Time, limit int) (int64, error) {
+	sel, err := s.query(`SELECT id FROM {table} WHERE published_at IS NOT NULL AND published_at < ? ORDER BY id LIMIT ?`)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, sel, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	var ids []any
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	if s.ArchiveTable != "" {
+		cols := `id, topic, payload, metadata_json, created_at, published_at`
+		archive, err := s.query(`INSERT INTO {archive} (` + cols + `) SELECT ` + cols + ` FROM {table} WHERE id IN (` + in + `)`)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, archive, ids...); err != nil {
+			return 0, err
+		}
+	}
+	del, err := s.query(`DELETE FROM {table} WHERE id IN (` + in + `)`)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, del, ids...)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// scanEvents reads rows of eventColumns.
+func scanEvents(rows *sql.Rows, err error) ([]*Event, error) {
 	if err != nil {
 		return nil, err
 	}
@@ -126,21 +547,59 @@ func (s *SQLStore) Pending(ctx context.Context, limit int) ([]*Event, error) {
 	for rows.Next() {
 		var e Event
 		var metaJSON []byte
-		if err := rows.Scan(&e.ID, &e.Topic, &e.Payload, &metaJSON, &e.CreatedAt); err != nil {
+		var lastErr, key sql.NullString
+		if err := rows.Scan(&e.ID, &e.Topic, &e.Payload, &metaJSON, &e.CreatedAt, &e.Attempts, &lastErr, &key); err != nil {
 			return nil, err
 		}
+		e.LastError, e.OrderingKey = lastErr.String, key.String
 		_ = json.Unmarshal(metaJSON, &e.Metadata)
 		out = append(out, &e)
 	}
 	return out, rows.Err()
 }
 
-// MarkPublished marks the event as published.
+// Claim selects unpublished events whose lease is free or expired and leases them to owner.
+// Rows are selected with the dialect's SKIP LOCKED clause when available, and each
+// lease is taken with a conditional UPDATE, so concurrent claimers never share an event.
+func (s *SQLStore) Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*Event, error) {
+	sel, err := s.query(`SELECT ` + eventColumns + ` FROM {table} e` +
+		` WHERE ` + publishable + ` AND (lease_until IS NULL OR lease_until < ?) ORDER BY id LIMIT ? ` + s.dialect().SkipLocked())
+	if err != nil {
+		return nil, err
+	}
+	upd, err := s.query(`UPDATE {table} SET lease_owner = ?, lease_until = ? WHERE id = ? AND (lease_until IS NULL OR lease_until < ?)`)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	now := time.Now()
+	events, err := scanEvents(tx.QueryContext(ctx, sel, now, now, limit))
+	if err != nil {
+		return nil, err
+	}
+	out := events[:0]
+	for _, e := range events {
+		res, err := tx.ExecContext(ctx, upd, owner, now.Add(lease), e.ID, now)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			out = append(out, e)
+		}
+	}
+	return out, tx.Commit()
+}
+
+// MarkPublished marks the event as published and releases its lease.
 func (s *SQLStore) MarkPublished(ctx context.Context, id int64) error {
-	table := s.Table
-	if table == "" {
-		table = "outbox"
+	query, err := s.query(`UPDATE {table} SET published_at = ?, lease_owner = NULL, lease_until = NULL WHERE id = ?`)
+	if err != nil {
+		return err
 	}
-	_, err := s.DB.ExecContext(ctx, `UPDATE `+table+` SET published_at = ? WHERE id = ?`, time.Now(), id)
+	_, err = s.DB.ExecContext(ctx, query, time.Now(), id)
 	return err
 }
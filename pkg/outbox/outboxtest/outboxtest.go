@@ -0,0 +1,260 @@
+// Package outboxtest provides a conformance suite for outbox.Store implementations.
+// Optional interfaces (Claimer, RetryStore, Cleaner, Backlogger) are tested when implemented.
+//
+//	func TestStore(t *testing.T) {
+//		outboxtest.TestStore(t, func(t *testing.T) outbox.Store { return outbox.NewMemoryStore() })
+//	}
+package outboxtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/outbox"
+)
+
+// TestStore runs the conformance suite. newStore must return an empty store for each subtest.
+func TestStore(t *testing.T, newStore func(t *testing.T) outbox.Store) {
+	t.Helper()
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s outbox.Store)
+	}{
+		{"SavePending", testSavePending},
+		{"MarkPublished", testMarkPublished},
+		{"OrderingKey", testOrderingKey},
+		{"Claim", testClaim},
+		{"Retry", testRetry},
+		{"DeletePublished", testDeletePublished},
+		{"Backlog", testBacklog},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) { tt.run(t, newStore(t)) })
+	}
+}
+
+func save(t *testing.T, ctx context.Context, s outbox.Store, topic, payload string) {
+	t.Helper()
+	if err := s.Save(ctx, topic, []byte(payload), map[string]string{"payload": payload}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+}
+
+func pending(t *testing.T, s outbox.Store, limit int) []*outbox.Event {
+	t.Helper()
+	events, err := s.Pending(context.Background(), limit)
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	return events
+}
+
+// payloads returns the payloads of events, in order.
+func payloads(events []*outbox.Event) []string {
+	out := make([]string, len(events))
+	for i, e := range events {
+		out[i] = string(e.Payload)
+	}
+	return out
+}
+
+func expect(t *testing.T, what string, events []*outbox.Event, want ...string) {
+	t.Helper()
+	got := payloads(events)
+	if len(got) != len(want) {
+		t.Fatalf("%s = %v, want %v", what, got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("%s = %v, want %v", what, got, want)
+		}
+	}
+}
+
+func testSavePending(t *testing.T, s outbox.Store) {
+	ctx := context.Background()
+	save(t, ctx, s, "a", "1")
+	save(t, ctx, s, "b", "2")
+	save(t, ctx, s, "a", "3")
+
+	events := pending(t, s, 10)
+	expect(t, "Pending", events, "1", "2", "3")
+	for i, e := range events {
+		if i > 0 && e.ID <= events[i-1].ID {
+			t.Fatalf("Pending not in id order: %d after %d", e.ID, events[i-1].ID)
+		}
+		if e.CreatedAt.IsZero() {
+			t.Fatalf("event %d has zero CreatedAt", e.ID)
+		}
+		if e.Metadata["payload"] != string(e.Payload) {
+			t.Fatalf("event %d metadata = %v", e.ID, e.Metadata)
+		}
+	}
+	if events[1].Topic != "b" || !bytes.Equal(events[1].Payload, []byte("2")) {
+		t.Fatalf("event = %+v", events[1])
+	}
+	expect(t, "Pending(2)", pending(t, s, 2), "1", "2")
+}
+
+func testMarkPublished(t *testing.T, s outbox.Store) {
+	ctx := context.Background()
+	save(t, ctx, s, "a", "1")
+	save(t, ctx, s, "a", "2")
+	events := pending(t, s, 10)
+	if err := s.MarkPublished(ctx, events[0].ID); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	expect(t, "Pending", pending(t, s, 10), "2")
+}
+
+func testOrderingKey(t *testing.T, s outbox.Store) {
+	ctx := context.Background()
+	a, b := outbox.WithOrderingKey(ctx, "a"), outbox.WithOrderingKey(ctx, "b")
+	save(t, a, s, "t", "a1")
+	save(t, b, s, "t", "b1")
+	save(t, a, s, "t", "a2")
+	save(t, ctx, s, "t", "n1")
+
+	events := pending(t, s, 10)
+	expect(t, "Pending", events, "a1", "b1", "n1")
+	if events[0].OrderingKey != "a" || events[2].OrderingKey != "" {
+		t.Fatalf("ordering keys = %q, %q", events[0].OrderingKey, events[2].OrderingKey)
+	}
+	if err := s.MarkPublished(ctx, events[0].ID); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	expect(t, "Pending after a1", pending(t, s, 10), "b1", "a2", "n1")
+}
+
+func testClaim(t *testing.T, s outbox.Store) {
+	c, ok := s.(outbox.Claimer)
+	if !ok {
+		t.Skip("store does not implement outbox.Claimer")
+	}
+	ctx := context.Background()
+	save(t, ctx, s, "t", "1")
+	save(t, ctx, s, "t", "2")
+	save(t, ctx, s, "t", "3")
+
+	lease := 200 * time.Millisecond
+	first, err := c.Claim(ctx, "one", 2, lease)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	expect(t, "Claim(one)", first, "1", "2")
+	second, err := c.Claim(ctx, "two", 10, lease)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	expect(t, "Claim(two)", second, "3")
+
+	if err := s.MarkPublished(ctx, first[0].ID); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	time.Sleep(lease + 50*time.Millisecond)
+	again, err := c.Claim(ctx, "two", 10, lease)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	expect(t, "Claim after lease expiry", again, "2", "3")
+}
+
+func testRetry(t *testing.T, s outbox.Store) {
+	rs, ok := s.(outbox.RetryStore)
+	if !ok {
+		t.Skip("store does not implement outbox.RetryStore")
+	}
+	ctx := context.Background()
+	save(t, ctx, s, "t", "later")
+	save(t, ctx, s, "t", "dead")
+	save(t, ctx, s, "t", "ok")
+	events := pending(t, s, 10)
+
+	if err := rs.MarkFailed(ctx, events[0].ID, "boom", time.Now().Add(time.Hour), false); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+	if err := rs.MarkFailed(ctx, events[1].ID, "first", time.Now(), false); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+	if err := rs.MarkFailed(ctx, events[1].ID, "gone", time.Now(), true); err != nil {
+		t.Fatalf("MarkFailed: %v", err)
+	}
+	expect(t, "Pending", pending(t, s, 10), "ok")
+
+	failed, err := rs.Failed(ctx, 10)
+	if err != nil {
+		t.Fatalf("Failed: %v", err)
+	}
+	expect(t, "Failed", failed, "dead")
+	if failed[0].Attempts != 2 || failed[0].LastError != "gone" {
+		t.Fatalf("dead event attempts=%d last_error=%q, want 2 %q", failed[0].Attempts, failed[0].LastError, "gone")
+	}
+
+	if err := rs.Requeue(ctx, failed[0].ID); err != nil {
+		t.Fatalf("Requeue: %v", err)
+	}
+	events = pending(t, s, 10)
+	expect(t, "Pending after Requeue", events, "dead", "ok")
+	if events[0].Attempts != 0 {
+		t.Fatalf("requeued event attempts = %d, want 0", events[0].Attempts)
+	}
+	if err := rs.Requeue(ctx, events[1].ID); !errors.Is(err, outbox.ErrEventNotFound) {
+		t.Fatalf("Requeue of a live event = %v, want ErrEventNotFound", err)
+	}
+}
+
+func testDeletePublished(t *testing.T, s outbox.Store) {
+	c, ok := s.(outbox.Cleaner)
+	if !ok {
+		t.Skip("store does not implement outbox.Cleaner")
+	}
+	ctx := context.Background()
+	for _, p := range []string{"1", "2", "3", "4"} {
+		save(t, ctx, s, "t", p)
+	}
+	events := pending(t, s, 10)
+	for _, e := range events[:3] {
+		if err := s.MarkPublished(ctx, e.ID); err != nil {
+			t.Fatalf("MarkPublished: %v", err)
+		}
+	}
+
+	if n, err := c.DeletePublished(ctx, time.Now().Add(-time.Hour), 10); err != nil || n != 0 {
+		t.Fatalf("DeletePublished(recent) = %d, %v; want 0", n, err)
+	}
+	before := time.Now().Add(time.Second)
+	if n, err := c.DeletePublished(ctx, before, 2); err != nil || n != 2 {
+		t.Fatalf("DeletePublished(limit 2) = %d, %v; want 2", n, err)
+	}
+	if n, err := c.DeletePublished(ctx, before, 10); err != nil || n != 1 {
+		t.Fatalf("DeletePublished = %d, %v; want 1", n, err)
+	}
+	expect(t, "Pending", pending(t, s, 10), "4")
+}
+
+func testBacklog(t *testing.T, s outbox.Store) {
+	b, ok := s.(outbox.Backlogger)
+	if !ok {
+		t.Skip("store does not implement outbox.Backlogger")
+	}
+	ctx := context.Background()
+	if n, oldest, err := b.Backlog(ctx); err != nil || n != 0 || !oldest.IsZero() {
+		t.Fatalf("Backlog(empty) = %d, %v, %v", n, oldest, err)
+	}
+	save(t, ctx, s, "t", "1")
+	save(t, ctx, s, "t", "2")
+	events := pending(t, s, 10)
+	if err := s.MarkPublished(ctx, events[0].ID); err != nil {
+		t.Fatalf("MarkPublished: %v", err)
+	}
+	n, oldest, err := b.Backlog(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("Backlog = %d, %v; want 1", n, err)
+	}
+	if !oldest.Equal(events[1].CreatedAt) {
+		t.Fatalf("Backlog oldest = %v, want %v", oldest, events[1].CreatedAt)
+	}
+}
@@ -0,0 +1,316 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the outbox schema version this package expects.
+const SchemaVersion = 2
+
+// ErrSchemaMismatch is returned by CheckSchema when the database schema is not SchemaVersion.
+var ErrSchemaMismatch = errors.New("outbox: schema version mismatch")
+
+// migrations holds, per dialect name, the statements upgrading the schema from
+// version i to i+1. {table}, {published_idx} and {ordering_idx} are replaced with quoted names.
+var migrations = map[string][][]string{
+	"postgres": {
+		{
+			`CREATE TABLE IF NOT EXISTS {table} (
+	id              BIGSERIAL PRIMARY KEY,
+	topic           TEXT NOT NULL,
+	payload         BYTEA,
+	metadata_json   TEXT,
+	created_at      TIMESTAMPTZ NOT NULL,
+	published_at    TIMESTAMPTZ,
+	lease_owner     TEXT,
+	lease_until     TIMESTAMPTZ,
+	attempts        INT NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	next_attempt_at TIMESTAMPTZ,
+	failed_at       TIMESTAMPTZ
+)`,
+			`CREATE INDEX IF NOT EXISTS {published_idx} ON {table} (published_at, id)`,
+		},
+		{
+			`ALTER TABLE {table} ADD COLUMN ordering_key TEXT`,
+			`CREATE INDEX IF NOT EXISTS {ordering_idx} ON {table} (ordering_key, id)`,
+		},
+	},
+	"mysql": {
+		{
+			`CREATE TABLE IF NOT EXISTS {table} (
+	id              BIGINT AUTO_INCREMENT PRIMARY KEY,
+	topic           VARCHAR(255) NOT NULL,
+	payload         LONGBLOB,
+	metadata_json   TEXT,
+	created_at      DATETIME(6) NOT NULL,
+	published_at    DATETIME(6) NULL,
+	lease_owner     VARCHAR(255) NULL,
+	lease_until     DATETIME(6) NULL,
+	attempts        INT NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	next_attempt_at DATETIME(6) NULL,
+	failed_at       DATETIME(6) NULL,
+	INDEX {published_idx} (published_at, id)
+)`,
+		},
+		{
+			`ALTER TABLE {table} ADD COLUMN ordering_key VARCHAR(255) NULL, ADD INDEX {ordering_idx} (ordering_key, id)`,
+		},
+	},
+	"sqlite": {
+		{
+			`CREATE TABLE IF NOT EXISTS {table} (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic           TEXT NOT NULL,
+	payload         BLOB,
+	metadata_json   TEXT,
+	created_at      TIMESTAMP NOT NULL,
+	published_at    TIMESTAMP,
+	lease_owner     TEXT,
+	lease_until     TIMESTAMP,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	last_error      TEXT,
+	next_attempt_at TIMESTAMP,
+	failed_at       TIMESTAMP
+)`,
+			`CREATE INDEX IF NOT EXISTS {published_idx} ON {table} (published_at, id)`,
+		},
+		{
+			`ALTER TABLE {table} ADD COLUMN ordering_key TEXT`,
+			`CREATE INDEX IF NOT EXISTS {ordering_idx} ON {table} (ordering_key, id)`,
+		},
+	},
+}
+
+// legacyColumns are the version 1 columns missing from tables created before
+// versioning (id, topic, payload, metadata_json, created_at, published_at), in
+// "name type" form per dialect. CreateSchema adds those a legacy table lacks.
+var legacyColumns = map[string][]string{
+	"postgres": {
+		"lease_owner TEXT", "lease_until TIMESTAMPTZ", "attempts INT NOT NULL DEFAULT 0",
+		"last_error TEXT", "next_attempt_at TIMESTAMPTZ", "failed_at TIMESTAMPTZ",
+	},
+	"mysql": {
+		"lease_owner VARCHAR(255) NULL", "lease_until DATETIME(6) NULL", "attempts INT NOT NULL DEFAULT 0",
+		"last_error TEXT", "next_attempt_at DATETIME(6) NULL", "failed_at DATETIME(6) NULL",
+	},
+	"sqlite": {
+		"lease_owner TEXT", "lease_until TIMESTAMP", "attempts INTEGER NOT NULL DEFAULT 0",
+		"last_error TEXT", "next_attempt_at TIMESTAMP", "failed_at TIMESTAMP",
+	},
+}
+
+// schemaColumns are all columns the store uses; CheckSchema probes for them.
+const schemaColumns = `id, topic, payload, metadata_json, created_at, published_at, lease_owner, lease_until,
+	attempts, last_error, next_attempt_at, failed_at, ordering_key`
+
+// schemaQuery is query with {versions} (the "<table>_schema" version table) and
+// the index names also replaced.
+func (s *SQLStore) schemaQuery(q string) (string, error) {
+	name := s.table().Name
+	if strings.Contains(q, "{versions}") {
+		versions, err := s.quote(name + "_schema")
+		if err != nil {
+			return "", err
+		}
+		q = strings.ReplaceAll(q, "{versions}", versions)
+	}
+	base := name[strings.LastIndex(name, ".")+1:]
+	q = strings.ReplaceAll(q, "{published_idx}", s.dialect().Quote(base+"_published_idx"))
+	q = strings.ReplaceAll(q, "{ordering_idx}", s.dialect().Quote(base+"_ordering_idx"))
+	return s.query(q)
+}
+
+// CreateSchema creates the outbox table and indexes for s.Dialect, or upgrades an
+// older schema created by this package, and records the version in "<table>_schema".
+// An existing unversioned table (the original id, topic, payload, metadata_json,
+// created_at, published_at layout) is upgraded by adding the missing columns.
+// Run it from a single instance (e.g. a deploy step); Dialect must be set.
+func (s *SQLStore) CreateSchema(ctx context.Context) error {
+	if s.Dialect == nil {
+		return errors.New("outbox: CreateSchema requires SQLStore.Dialect")
+	}
+	migs, ok := migrations[s.Dialect.Name()]
+	if !ok {
+		return fmt.Errorf("outbox: no schema for dialect %q", s.Dialect.Name())
+	}
+	create, err := s.schemaQuery(`CREATE TABLE IF NOT EXISTS {versions} (version INT PRIMARY KEY, applied_at TIMESTAMP NOT NULL)`)
+	if err != nil {
+		return err
+	}
+	if _, err := s.DB.ExecContext(ctx, create); err != nil {
+		return err
+	}
+	current, err := s.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	record, err := s.schemaQuery(`INSERT INTO {versions} (version, applied_at) VALUES (?, ?)`)
+	if err != nil {
+		return err
+	}
+	for v := current + 1; v <= len(migs); v++ {
+		stmts := migs[v-1]
+		if v == 1 {
+			legacy, err := s.legacyUpgrade(ctx)
+			if err != nil {
+				return fmt.Errorf("outbox: migrate to version 1: %w", err)
+			}
+			stmts = append(legacy, stmts...)
+		}
+		if err := s.migrate(ctx, stmts, record, v); err != nil {
+			return fmt.Errorf("outbox: migrate to version %d: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// legacyUpgrade returns the statements adding the version 1 columns missing from
+// an existing table, or nil if the table does not exist yet. Columns are probed
+// outside the migration transaction, as a failed query aborts it on Postgres.
+func (s *SQLStore) legacyUpgrade(ctx context.Context) ([]string, error) {
+	if !s.probe(ctx, "id") {
+		return nil, nil
+	}
+	var stmts []string
+	for _, col := range legacyColumns[s.Dialect.Name()] {
+		if !s.probe(ctx, col[:strings.IndexByte(col, ' ')]) {
+			stmts = append(stmts, `ALTER TABLE {table} ADD COLUMN `+col)
+		}
+	}
+	if s.Dialect.Name() == "mysql" {
+		// the index is declared inline in CREATE TABLE, which a legacy table skipped
+		stmts = append(stmts, `CREATE INDEX {published_idx} ON {table} (published_at, id)`)
+	}
+	return stmts, nil
+}
+
+// probe reports whether the table has the given columns.
+func (s *SQLStore) probe(ctx context.Context, columns string) bool {
+	q, err := s.query(`SELECT ` + columns + ` FROM {table} WHERE 1 = 0`)
+	if err != nil {
+		return false
+	}
+	rows, err := s.DB.QueryContext(ctx, q)
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+// migrate applies one version's statements and records it in a transaction.
+// MySQL commits DDL implicitly, so a failed step may be left partially applied there.
+func (s *SQLStore) migrate(ctx context.Context, stmts []string, record string, version int) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		q, err := s.schemaQuery(stmt)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, version, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// schemaVersion returns the latest recorded version, 0 if none.
+func (s *SQLStore) schemaVersion(ctx context.Context) (int, error) {
+	q, err := s.schemaQuery(`SELECT MAX(version) FROM {versions}`)
+	if err != nil {
+		return 0, err
+	}
+	var v *int64
+	if err := s.DB.QueryRowContext(ctx, q).Scan(&v); err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, nil
+	}
+	return int(*v), nil
+}
+
+// CheckSchema verifies that the recorded schema version is SchemaVersion and
+// that the table has every column the store uses. Call it at startup to fail fast.
+func (s *SQLStore) CheckSchema(ctx context.Context) error {
+	v, err := s.schemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("outbox: check schema: %w", err)
+	}
+	if v != SchemaVersion {
+		return fmt.Errorf("%w: database has %d, want %d", ErrSchemaMismatch, v, SchemaVersion)
+	}
+	probe, err := s.query(`SELECT ` + schemaColumns + ` FROM {table} WHERE 1 = 0`)
+	if err != nil {
+		return err
+	}
+	rows, err := s.DB.QueryContext(ctx, probe) // error names the missing column
+	if err != nil {
+		return fmt.Errorf("outbox: check schema: %w", err)
+	}
+	return rows.Close()
+}
+
+// DefaultNotifyChannel is the Postgres channel used by CreateNotifyTrigger.
+const DefaultNotifyChannel = "outbox"
+
+// CreateNotifyTrigger creates (or replaces) a Postgres trigger that sends
+// NOTIFY on channel (default DefaultNotifyChannel) when events are inserted.
+// Notifications are delivered on commit. database/sql cannot LISTEN; use the
+// driver's listener (lib/pq Listener, pgx WaitForNotification) and forward each
+// notification to the channel passed to WithWakeup.
+func (s *SQLStore) CreateNotifyTrigger(ctx context.Context, channel string) error {
+	if s.Dialect == nil || s.Dialect.Name() != "postgres" {
+		return errors.New("outbox: CreateNotifyTrigger requires the postgres dialect")
+	}
+	if channel == "" {
+		channel = DefaultNotifyChannel
+	}
+	if _, err := s.quote(channel); err != nil {
+		return err
+	}
+	name := s.table().Name
+	fn, err := s.quote(name + "_notify")
+	if err != nil {
+		return err
+	}
+	trigger := s.Dialect.Quote(name[strings.LastIndex(name, ".")+1:] + "_notify")
+	stmts := []string{
+		`CREATE OR REPLACE FUNCTION ` + fn + `() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('` + channel + `', '');
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`,
+		`DROP TRIGGER IF EXISTS ` + trigger + ` ON {table}`,
+		`CREATE TRIGGER ` + trigger + ` AFTER INSERT ON {table} FOR EACH STATEMENT EXECUTE FUNCTION ` + fn + `()`,
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, stmt := range stmts {
+		q, err := s.query(stmt)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
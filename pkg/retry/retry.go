@@ -4,6 +4,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"math/rand"
 	"time"
 )
@@ -28,11 +29,10 @@ func DefaultConfig() Config {
 	}
 }
 
-// Do runs fn until success, context cancellation or attempts exhausted.
-// Returns the last error from fn.
+// Do runs fn until success, context cancellation, attempts exhausted or a
+// Permanent error. Returns the last error from fn.
 func Do(ctx context.Context, cfg Config, fn func() error) error {
 	var lastErr error
-	backoff := cfg.Initial
 	for attempt := 0; attempt < cfg.MaxAttempts; attempt++ {
 		if err := ctx.Err(); err != nil {
 			return err
@@ -41,27 +41,50 @@ func Do(ctx context.Context, cfg Config, fn func() error) error {
 		if lastErr == nil {
 			return nil
 		}
+		var perm *permanentError
+		if errors.As(lastErr, &perm) {
+			return perm.err
+		}
 		if attempt == cfg.MaxAttempts-1 {
 			break
 		}
-		// backoff with jitter
-		d := addJitter(backoff, cfg.Jitter)
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
-		case <-time.After(d):
-			// next backoff
-		}
-		if cfg.Multiplier > 0 {
-			backoff = time.Duration(float64(backoff) * cfg.Multiplier)
-			if cfg.MaxBackoff > 0 && backoff > cfg.MaxBackoff {
-				backoff = cfg.MaxBackoff
-			}
+		case <-time.After(Backoff(cfg, attempt+1)):
+			// next attempt
 		}
 	}
 	return lastErr
 }
 
+// Permanent wraps err so that Do stops retrying and returns err (nil stays nil).
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+type permanentError struct{ err error }
+
+func (p *permanentError) Error() string { return p.err.Error() }
+func (p *permanentError) Unwrap() error { return p.err }
+
+// Backoff returns the jittered delay to wait after the given attempt (1-based).
+// Useful when attempts are persisted and rescheduled instead of run in a loop.
+func Backoff(cfg Config, attempt int) time.Duration {
+	d := cfg.Initial
+	for i := 1; i < attempt && cfg.Multiplier > 0; i++ {
+		d = time.Duration(float64(d) * cfg.Multiplier)
+		if cfg.MaxBackoff > 0 && d > cfg.MaxBackoff {
+			d = cfg.MaxBackoff
+			break
+		}
+	}
+	return addJitter(d, cfg.Jitter)
+}
+
 func addJitter(d time.Duration, jitter float64) time.Duration {
 	if jitter <= 0 || jitter > 1 {
 		return d
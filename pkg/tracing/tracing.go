@@ -6,6 +6,7 @@ import (
 	"context"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -15,11 +16,22 @@ func Tracer(name string) trace.Tracer {
 }
 
 // StartSpan starts a span in the context and returns the context and span.
-func StartSpan(ctx context.Context, tracerName, spanName string) (context.Context, trace.Span) {
-	return otel.Tracer(tracerName).Start(ctx, spanName)
+func StartSpan(ctx context.Context, tracerName, spanName string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, spanName, opts...)
 }
 
 // SpanFromContext returns the span from the context, if any.
 func SpanFromContext(ctx context.Context) trace.Span {
 	return trace.SpanFromContext(ctx)
 }
+
+// Inject writes the span context of ctx into carrier as W3C traceparent/tracestate
+// (e.g. message headers or stored metadata).
+func Inject(ctx context.Context, carrier map[string]string) {
+	propagation.TraceContext{}.Inject(ctx, propagation.MapCarrier(carrier))
+}
+
+// Extract returns ctx with the remote span context read from carrier, if any.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier(carrier))
+}
@@ -0,0 +1,184 @@
+// Package worker: durable pool that runs persisted jobs through a handler registry.
+
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/db"
+	"github.com/cosmos-toolkit/pkgs/pkg/retry"
+)
+
+// Handler processes the payload of a stored job.
+type Handler func(ctx context.Context, payload []byte) error
+
+// Registry maps job type names to handlers.
+type Registry struct {
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{handlers: make(map[string]Handler)}
+}
+
+// Register sets the handler for jobType (replaces any previous one).
+func (r *Registry) Register(jobType string, h Handler) {
+	r.mu.Lock()
+	r.handlers[jobType] = h
+	r.mu.Unlock()
+}
+
+// Handler returns the handler for jobType.
+func (r *Registry) Handler(jobType string) (Handler, bool) {
+	r.mu.RLock()
+	h, ok := r.handlers[jobType]
+	r.mu.RUnlock()
+	return h, ok
+}
+
+// DurableConfig configures the durable pool.
+type DurableConfig struct {
+	Pool         Config        // concurrency; Pool.Retry is ignored (attempts are persisted)
+	Owner        string        // lease owner (default db.DefaultOwner)
+	PollInterval time.Duration // default 1s
+	Lease        time.Duration // must exceed the longest job run (default 5m)
+	Backoff      retry.Config  // delay between attempts; MaxAttempts comes from each job
+}
+
+// DefaultDurableConfig returns default configuration.
+func DefaultDurableConfig() DurableConfig {
+	return DurableConfig{
+		Pool:         DefaultConfig(),
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		Backoff:      retry.DefaultConfig(),
+	}
+}
+
+// DurablePool claims jobs from a Store and runs them on a Pool.
+// Failed jobs are rescheduled with backoff; finished jobs are archived.
+type DurablePool struct {
+	*Pool
+	store    Store
+	registry *Registry
+	cfg      DurableConfig
+	jobs     chan Job
+	inflight atomic.Int64
+	done     chan struct{}
+	wg       sync.WaitGroup
+	stop     sync.Once
+}
+
+// NewDurablePool creates a durable pool. Use Start() to begin polling.
+func NewDurablePool(store Store, registry *Registry, cfg DurableConfig) *DurablePool {
+	if cfg.Pool.Concurrency < 1 {
+		cfg.Pool.Concurrency = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.Lease <= 0 {
+		cfg.Lease = 5 * time.Minute
+	}
+	if cfg.Owner == "" {
+		cfg.Owner = db.DefaultOwner()
+	}
+	cfg.Pool.Retry = retry.Config{MaxAttempts: 1}
+	jobs := make(chan Job)
+	return &DurablePool{
+		Pool:     NewPool(cfg.Pool, jobs),
+		store:    store,
+		registry: registry,
+		cfg:      cfg,
+		jobs:     jobs,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts the workers and the poller. Returns immediately.
+func (p *DurablePool) Start(ctx context.Context) {
+	p.Pool.Start(ctx)
+	p.wg.Add(1)
+	go p.poll(ctx)
+}
+
+// Stop stops polling and waits for running jobs. Unstarted claimed jobs are
+// picked up again after their lease expires.
+func (p *DurablePool) Stop() {
+	p.stop.Do(func() { close(p.done) })
+	p.wg.Wait()
+	p.Pool.Stop()
+}
+
+func (p *DurablePool) poll(ctx context.Context) {
+	defer p.wg.Done()
+	tick := time.NewTicker(p.cfg.PollInterval)
+	defer tick.Stop()
+	for {
+		p.claim(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func (p *DurablePool) claim(ctx context.Context) {
+	free := p.cfg.Pool.Concurrency - int(p.inflight.Load())
+	if free <= 0 {
+		return
+	}
+	claimed, err := p.store.Claim(ctx, p.cfg.Owner, free, p.cfg.Lease)
+	if err != nil {
+		return
+	}
+	for _, sj := range claimed {
+		p.inflight.Add(1)
+		select {
+		case p.jobs <- &durableJob{pool: p, job: sj}:
+		case <-ctx.Done():
+			return
+		case <-p.done:
+			return
+		}
+	}
+}
+
+type durableJob struct {
+	pool *DurablePool
+	job  *StoredJob
+}
+
+func (d *durableJob) Run(ctx context.Context) error {
+	defer d.pool.inflight.Add(-1)
+	store, j := d.pool.store, d.job
+	if j.Attempts > j.MaxAttempts {
+		// lease expired during the last attempt (e.g. process crashed)
+		return store.Archive(ctx, j, StatusFailed, j.LastError)
+	}
+	h, ok := d.pool.registry.Handler(j.Type)
+	if !ok {
+		err := fmt.Errorf("worker: no handler registered for job type %q", j.Type)
+		_ = store.Archive(ctx, j, StatusFailed, err.Error())
+		return err
+	}
+	err := h(ctx, j.Payload)
+	if err == nil {
+		return store.Archive(ctx, j, StatusDone, "")
+	}
+	if j.Attempts >= j.MaxAttempts {
+		_ = store.Archive(ctx, j, StatusFailed, err.Error())
+		return err
+	}
+	_ = store.Reschedule(ctx, j, time.Now().Add(retry.Backoff(d.pool.cfg.Backoff, j.Attempts)), err.Error())
+	return err
+}
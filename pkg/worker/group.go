@@ -0,0 +1,165 @@
+// Package worker: job groups (fan-out/fan-in) with progress and completion callbacks.
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// completer is implemented by jobs that want their final outcome (after retries).
+// stopped is closed when the pool that ran the job stops.
+type completer interface {
+	complete(ctx context.Context, err error, stopped <-chan struct{})
+}
+
+// ErrPoolStopped is recorded in GroupResult.ThenErr when the pool stopped before Then was sent.
+var ErrPoolStopped = errors.New("worker: pool stopped")
+
+// JobFailure is a job of a group that failed after all attempts.
+type JobFailure struct {
+	Index int // position in the submitted slice
+	Err   error
+}
+
+// GroupResult is the outcome of a finished group.
+type GroupResult struct {
+	Total     int
+	Succeeded int
+	Failures  []JobFailure
+	ThenErr   error // set if Then was not sent: ctx was cancelled or the pool stopped (ErrPoolStopped)
+}
+
+// Failed reports whether any job of the group failed.
+func (r GroupResult) Failed() bool { return len(r.Failures) > 0 }
+
+// GroupOptions configures what runs when every job of a group has finished.
+type GroupOptions struct {
+	// OnComplete runs once, on the worker that finished the last job, with partial failures.
+	OnComplete func(ctx context.Context, res GroupResult)
+	// Then is sent to the group's channel after OnComplete (e.g. merge imported chunks).
+	// Done is closed once it has been sent, so the channel may be closed after Wait returns.
+	// If ctx is cancelled or the pool stops first, Then is dropped and ThenErr says why.
+	Then Job
+}
+
+// Group tracks a set of jobs submitted together.
+type Group struct {
+	opts   GroupOptions
+	jobsCh chan<- Job
+	mu     sync.Mutex
+	res    GroupResult
+	done   chan struct{}
+}
+
+// Submit sends jobs to jobsCh (the channel read by a Pool) as a group and returns its handle.
+// If ctx is cancelled before every job is sent, unsent jobs are recorded as failed with ctx.Err().
+func Submit(ctx context.Context, jobsCh chan<- Job, jobs []Job, opts GroupOptions) (*Group, error) {
+	g := &Group{
+		opts:   opts,
+		jobsCh: jobsCh,
+		res:    GroupResult{Total: len(jobs)},
+		done:   make(chan struct{}),
+	}
+	if len(jobs) == 0 {
+		g.finalize(ctx, nil)
+		return g, nil
+	}
+	for i, j := range jobs {
+		select {
+		case jobsCh <- &groupJob{Job: j, group: g, index: i, enqueued: time.Now()}:
+		case <-ctx.Done():
+			for k := i; k < len(jobs); k++ {
+				g.finish(ctx, k, ctx.Err(), nil)
+			}
+			return g, ctx.Err()
+		}
+	}
+	return g, nil
+}
+
+// Progress returns how many jobs finished, how many of those failed, and the total.
+func (g *Group) Progress() (finished, failed, total int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.res.Succeeded + len(g.res.Failures), len(g.res.Failures), g.res.Total
+}
+
+// Done is closed when every job finished, OnComplete returned and Then was sent.
+func (g *Group) Done() <-chan struct{} { return g.done }
+
+// Wait blocks until the group is done or ctx is cancelled.
+func (g *Group) Wait(ctx context.Context) (GroupResult, error) {
+	select {
+	case <-g.done:
+		return g.result(), nil
+	case <-ctx.Done():
+		return GroupResult{}, ctx.Err()
+	}
+}
+
+func (g *Group) result() GroupResult {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	res := g.res
+	res.Failures = append([]JobFailure(nil), g.res.Failures...)
+	return res
+}
+
+func (g *Group) finish(ctx context.Context, index int, err error, stopped <-chan struct{}) {
+	g.mu.Lock()
+	if err != nil {
+		g.res.Failures = append(g.res.Failures, JobFailure{Index: index, Err: err})
+	} else {
+		g.res.Succeeded++
+	}
+	last := g.res.Succeeded+len(g.res.Failures) == g.res.Total
+	g.mu.Unlock()
+	if last {
+		g.finalize(ctx, stopped)
+	}
+}
+
+func (g *Group) finalize(ctx context.Context, stopped <-chan struct{}) {
+	if g.opts.OnComplete != nil {
+		g.opts.OnComplete(ctx, g.result())
+	}
+	if g.opts.Then == nil {
+		close(g.done)
+		return
+	}
+	// send from a goroutine: the calling worker may be the only reader of jobsCh
+	go func() {
+		var err error
+		select {
+		case g.jobsCh <- g.opts.Then:
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-stopped:
+			err = ErrPoolStopped
+		}
+		if err != nil {
+			g.mu.Lock()
+			g.res.ThenErr = err
+			g.mu.Unlock()
+		}
+		close(g.done)
+	}()
+}
+
+type groupJob struct {
+	Job
+	group    *Group
+	index    int
+	enqueued time.Time
+}
+
+func (j *groupJob) Name() string        { return jobType(j.Job) }
+func (j *groupJob) RateKey() string     { return rateKey(j.Job) }
+func (j *groupJob) Enqueued() time.Time { return enqueuedAt(j.Job, j.enqueued) }
+
+func (j *groupJob) complete(ctx context.Context, err error, stopped <-chan struct{}) {
+	j.group.finish(ctx, j.index, err, stopped)
+}
@@ -4,6 +4,7 @@ package worker
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/cosmos-toolkit/pkgs/pkg/metrics"
@@ -13,7 +14,29 @@ import (
 	"go.opentelemetry.io/otel/codes"
 )
 
-// InstrumentedPool wraps a Pool and instruments each job with tracing and metrics.
+// Named is implemented by jobs that report a type name (used as the job_type label).
+type Named interface {
+	Name() string
+}
+
+// EnqueueTimed is implemented by jobs that record when they were enqueued (e.g. jobs
+// submitted with Submit). queue_wait is then measured from that time; for other jobs
+// it starts when the instrumented pool reads the job from its channel, so time spent
+// waiting in an unbuffered or full channel is not counted.
+type EnqueueTimed interface {
+	Enqueued() time.Time
+}
+
+func enqueuedAt(j Job, fallback time.Time) time.Time {
+	if e, ok := j.(EnqueueTimed); ok {
+		if t := e.Enqueued(); !t.IsZero() {
+			return t
+		}
+	}
+	return fallback
+}
+
+// InstrumentedPool wraps a Pool and instruments each job attempt with tracing and metrics.
 type InstrumentedPool struct {
 	*Pool
 }
@@ -21,32 +44,49 @@ type InstrumentedPool struct {
 // InstrumentedPoolConfig configures the instrumented pool.
 type InstrumentedPoolConfig struct {
 	TracerName string
-	JobName   string
+	JobName    string                // metric name prefix
+	Registerer prometheus.Registerer // default: prometheus.DefaultRegisterer
 }
 
 // DefaultInstrumentedConfig returns default instrumentation config.
 func DefaultInstrumentedConfig() InstrumentedPoolConfig {
 	return InstrumentedPoolConfig{
 		TracerName: "worker",
-		JobName:   "worker_jobs",
+		JobName:    "worker_jobs",
 	}
 }
 
-// NewInstrumentedPool creates a pool that instruments each job with spans and metrics.
+type poolMetrics struct {
+	latency   *prometheus.HistogramVec
+	queueWait *prometheus.HistogramVec
+	total     *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	retries   *prometheus.CounterVec
+	inFlight  *prometheus.GaugeVec
+}
+
+// NewInstrumentedPool creates a pool that instruments each job attempt with spans and metrics.
+// Pools sharing JobName and Registerer share the same collectors.
 func NewInstrumentedPool(cfg Config, jobsCh <-chan Job, inst InstrumentedPoolConfig) *InstrumentedPool {
-	latency := metrics.Histogram(inst.JobName+"_duration_seconds", "Job execution latency in seconds", nil)
-	total := metrics.Counter(inst.JobName+"_total", "Total jobs processed")
-	errors := metrics.Counter(inst.JobName+"_errors_total", "Total job errors")
+	reg, name := inst.Registerer, inst.JobName
+	m := &poolMetrics{
+		latency:   metrics.HistogramVecWith(reg, name+"_duration_seconds", "Job attempt latency in seconds", nil, "job_type"),
+		queueWait: metrics.HistogramVecWith(reg, name+"_queue_wait_seconds", "Time from enqueue (see EnqueueTimed) to first attempt in seconds", nil, "job_type"),
+		total:     metrics.CounterVecWith(reg, name+"_total", "Total job attempts", "job_type"),
+		errors:    metrics.CounterVecWith(reg, name+"_errors_total", "Total job attempt errors", "job_type"),
+		retries:   metrics.CounterVecWith(reg, name+"_retries_total", "Total job retry attempts", "job_type"),
+		inFlight:  metrics.GaugeVecWith(reg, name+"_in_flight", "Job attempts currently running", "job_type"),
+	}
 
 	wrapped := make(chan Job, cfg.Concurrency*2)
 	go func() {
 		for j := range jobsCh {
 			wrapped <- &instrumentedJob{
 				Job:        j,
+				jobType:    jobType(j),
 				tracerName: inst.TracerName,
-				latency:    latency.(prometheus.Observer),
-				total:      total,
-				errors:     errors,
+				metrics:    m,
+				enqueued:   enqueuedAt(j, time.Now()),
 			}
 		}
 		close(wrapped)
@@ -55,26 +95,59 @@ func NewInstrumentedPool(cfg Config, jobsCh <-chan Job, inst InstrumentedPoolCon
 	return &InstrumentedPool{Pool: NewPool(cfg, wrapped)}
 }
 
+func jobType(j Job) string {
+	if n, ok := j.(Named); ok {
+		return n.Name()
+	}
+	return "unknown"
+}
+
 type instrumentedJob struct {
 	Job        Job
+	jobType    string
 	tracerName string
-	latency    prometheus.Observer
-	total      prometheus.Counter
-	errors     prometheus.Counter
+	metrics    *poolMetrics
+	enqueued   time.Time
+	attempts   atomic.Int64
+}
+
+// RateKey forwards the wrapped job's rate limit key.
+func (j *instrumentedJob) RateKey() string { return rateKey(j.Job) }
+
+func (j *instrumentedJob) complete(ctx context.Context, err error, stopped <-chan struct{}) {
+	if c, ok := j.Job.(completer); ok {
+		c.complete(ctx, err, stopped)
+	}
 }
 
+// Run is called by the pool once per attempt.
 func (j *instrumentedJob) Run(ctx context.Context) error {
 	start := time.Now()
+	attempt := j.attempts.Add(1)
+	if attempt == 1 {
+		j.metrics.queueWait.WithLabelValues(j.jobType).Observe(start.Sub(j.enqueued).Seconds())
+	} else {
+		j.metrics.retries.WithLabelValues(j.jobType).Inc()
+	}
+
 	ctx, span := tracing.StartSpan(ctx, j.tracerName, "job")
+	span.SetAttributes(
+		attribute.String("job.type", j.jobType),
+		attribute.Int64("job.attempt", attempt),
+	)
 	defer span.End()
 
+	inFlight := j.metrics.inFlight.WithLabelValues(j.jobType)
+	inFlight.Inc()
 	err := j.Job.Run(ctx)
-	j.total.Inc()
+	inFlight.Dec()
+
+	j.metrics.total.WithLabelValues(j.jobType).Inc()
 	if err != nil {
 		span.SetStatus(codes.Error, err.Error())
 		span.SetAttributes(attribute.Bool("error", true))
-		j.errors.Inc()
+		j.metrics.errors.WithLabelValues(j.jobType).Inc()
 	}
-	j.latency.Observe(time.Since(start).Seconds())
+	j.metrics.latency.WithLabelValues(j.jobType).Observe(time.Since(start).Seconds())
 	return err
 }
@@ -0,0 +1,116 @@
+// Package worker: token bucket rate limiting, global or per job key.
+
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimit configures a token bucket applied before each job attempt.
+// Zero Rate disables limiting.
+type RateLimit struct {
+	Rate  float64 // tokens per second
+	Burst int     // bucket size (default 1)
+	// PerKey gives each RateKey (see RateKeyed) its own bucket; jobs without a key share one.
+	// Buckets idle long enough to refill are evicted, so keys may be unbounded.
+	PerKey bool
+}
+
+// RateKeyed is implemented by jobs that are limited per key (e.g. tenant, partner API).
+type RateKeyed interface {
+	RateKey() string
+}
+
+func rateKey(j Job) string {
+	if k, ok := j.(RateKeyed); ok {
+		return k.RateKey()
+	}
+	return ""
+}
+
+type limiter struct {
+	cfg     RateLimit
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time // last evict
+}
+
+// evictInterval is how often take scans for idle buckets.
+const evictInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(cfg RateLimit) *limiter {
+	if cfg.Rate <= 0 {
+		return nil
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return &limiter{cfg: cfg, buckets: make(map[string]*bucket)}
+}
+
+// wait blocks until a token for key is available or ctx is done.
+// A nil limiter never blocks.
+func (l *limiter) wait(ctx context.Context, key string) error {
+	if l == nil {
+		return ctx.Err()
+	}
+	if !l.cfg.PerKey {
+		key = ""
+	}
+	for {
+		d := l.take(key)
+		if d == 0 {
+			return nil
+		}
+		t := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// take consumes a token and returns 0, or returns how long until one is available.
+func (l *limiter) take(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.swept) >= evictInterval {
+		l.evict(now)
+		l.swept = now
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.cfg.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.cfg.Rate
+	if burst := float64(l.cfg.Burst); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second))
+}
+
+// evict removes buckets that refilled to Burst: they behave like new ones. l.mu must be held.
+func (l *limiter) evict(now time.Time) {
+	burst := float64(l.cfg.Burst)
+	for k, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate >= burst {
+			delete(l.buckets, k)
+		}
+	}
+}
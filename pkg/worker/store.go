@@ -0,0 +1,227 @@
+// Package worker: durable job store with SQL persistence and lease-based claiming.
+
+package worker
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/cosmos-toolkit/pkgs/pkg/db"
+)
+
+// DefaultMaxAttempts is used when a job is enqueued without MaxAttempts.
+const DefaultMaxAttempts = 5
+
+// Archive statuses for finished jobs.
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+var (
+	// ErrDuplicateJob is returned by Enqueue when a job with the same unique key is still active.
+	ErrDuplicateJob = errors.New("worker: duplicate job unique key")
+	// ErrLeaseLost is returned when a job's lease expired and it was claimed by another owner.
+	ErrLeaseLost = errors.New("worker: job lease lost")
+)
+
+// StoredJob is a persisted job.
+type StoredJob struct {
+	ID          int64
+	Type        string
+	Payload     []byte
+	RunAt       time.Time
+	Attempts    int // attempts made, including the current one once claimed
+	MaxAttempts int
+	UniqueKey   string
+	LastError   string
+	LeaseOwner  string
+	CreatedAt   time.Time
+}
+
+// EnqueueOptions configures a job at enqueue time. Zero values use defaults.
+type EnqueueOptions struct {
+	RunAt       time.Time // default: now
+	MaxAttempts int       // default: DefaultMaxAttempts
+	UniqueKey   string    // optional; rejects the job while another with the same key is active
+}
+
+// Store persists jobs and claims them for execution.
+type Store interface {
+	Enqueue(ctx context.Context, jobType string, payload []byte, opts EnqueueOptions) error
+	// Claim leases up to limit due jobs to owner for lease and increments their attempts.
+	Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*StoredJob, error)
+	// Reschedule releases the lease and sets the next run time.
+	Reschedule(ctx context.Context, job *StoredJob, runAt time.Time, lastErr string) error
+	// Archive moves the job out of the active table with the given status.
+	Archive(ctx context.Context, job *StoredJob, status string, lastErr string) error
+}
+
+// SQLStore implements Store with a jobs table
+// (id, type, payload, run_at, attempts, max_attempts, unique_key, last_error, lease_owner, lease_until, created_at)
+// and an archive table with the same columns plus status and finished_at (lease columns omitted).
+// unique_key should have a unique index; use db.Open to create DB.
+type SQLStore struct {
+	DB           *sql.DB
+	Table        string     // default "jobs"; optionally schema-qualified
+	ArchiveTable string     // default "jobs_archive"; optionally schema-qualified
+	Dialect      db.Dialect // default db.Portable
+}
+
+// query replaces {table} and {archive} with the validated, quoted table names and rebinds placeholders.
+func (s *SQLStore) query(q string) (string, error) {
+	if strings.Contains(q, "{archive}") {
+		archive, err := db.Table{Name: cmp.Or(s.ArchiveTable, "jobs_archive"), Dialect: s.Dialect}.Quoted()
+		if err != nil {
+			return "", err
+		}
+		q = strings.ReplaceAll(q, "{archive}", archive)
+	}
+	return db.Table{Name: cmp.Or(s.Table, "jobs"), Dialect: s.Dialect}.Query(q)
+}
+
+// Enqueue inserts a job. Returns ErrDuplicateJob if UniqueKey is set and already active,
+// including when a concurrent Enqueue wins the race and the insert fails on the unique index.
+func (s *SQLStore) Enqueue(ctx context.Context, jobType string, payload []byte, opts EnqueueOptions) error {
+	now := time.Now()
+	if opts.RunAt.IsZero() {
+		opts.RunAt = now
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	var uniqueKey any
+	if opts.UniqueKey != "" {
+		dup, err := s.active(ctx, opts.UniqueKey)
+		if err != nil {
+			return err
+		}
+		if dup {
+			return ErrDuplicateJob
+		}
+		uniqueKey = opts.UniqueKey
+	}
+	query, err := s.query(`INSERT INTO {table} (type, payload, run_at, attempts, max_attempts, unique_key, created_at) VALUES (?, ?, ?, 0, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, query, jobType, payload, opts.RunAt, opts.MaxAttempts, uniqueKey, now)
+	if err != nil && opts.UniqueKey != "" {
+		// unique violations are driver-specific: check whether the key is now taken
+		if dup, cerr := s.active(ctx, opts.UniqueKey); cerr == nil && dup {
+			return ErrDuplicateJob
+		}
+	}
+	return err
+}
+
+// active reports whether a job with uniqueKey is in the jobs table.
+func (s *SQLStore) active(ctx context.Context, uniqueKey string) (bool, error) {
+	query, err := s.query(`SELECT COUNT(*) FROM {table} WHERE unique_key = ?`)
+	if err != nil {
+		return false, err
+	}
+	var n int
+	if err := s.DB.QueryRowContext(ctx, query, uniqueKey).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Claim selects due jobs whose lease is free or expired and leases them to owner.
+// Each row is leased with a conditional UPDATE, so concurrent claimers never get the same job.
+func (s *SQLStore) Claim(ctx context.Context, owner string, limit int, lease time.Duration) ([]*StoredJob, error) {
+	sel, err := s.query(`SELECT id, type, payload, run_at, attempts, max_attempts, unique_key, last_error, created_at FROM {table}` +
+		` WHERE run_at <= ? AND (lease_until IS NULL OR lease_until < ?) ORDER BY run_at, id LIMIT ?`)
+	if err != nil {
+		return nil, err
+	}
+	upd, err := s.query(`UPDATE {table} SET lease_owner = ?, lease_until = ?, attempts = attempts + 1 WHERE id = ? AND (lease_until IS NULL OR lease_until < ?)`)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	rows, err := s.DB.QueryContext(ctx, sel, now, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*StoredJob
+	for rows.Next() {
+		var j StoredJob
+		var uniqueKey, lastErr sql.NullString
+		if err := rows.Scan(&j.ID, &j.Type, &j.Payload, &j.RunAt, &j.Attempts, &j.MaxAttempts, &uniqueKey, &lastErr, &j.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		j.UniqueKey, j.LastError = uniqueKey.String, lastErr.String
+		candidates = append(candidates, &j)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var out []*StoredJob
+	for _, j := range candidates {
+		res, err := s.DB.ExecContext(ctx, upd, owner, now.Add(lease), j.ID, now)
+		if err != nil {
+			return out, err
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			continue // claimed by another owner
+		}
+		j.Attempts++
+		j.LeaseOwner = owner
+		out = append(out, j)
+	}
+	return out, nil
+}
+
+// Reschedule releases the lease and sets run_at. Returns ErrLeaseLost if the job is no longer leased by job.LeaseOwner.
+func (s *SQLStore) Reschedule(ctx context.Context, job *StoredJob, runAt time.Time, lastErr string) error {
+	query, err := s.query(`UPDATE {table} SET run_at = ?, last_error = ?, lease_owner = NULL, lease_until = NULL WHERE id = ? AND lease_owner = ?`)
+	if err != nil {
+		return err
+	}
+	res, err := s.DB.ExecContext(ctx, query, runAt, lastErr, job.ID, job.LeaseOwner)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Archive copies the job to the archive table and deletes it in a single transaction.
+func (s *SQLStore) Archive(ctx context.Context, job *StoredJob, status string, lastErr string) error {
+	ins, err := s.query(`INSERT INTO {archive} (id, type, payload, run_at, attempts, max_attempts, unique_key, last_error, status, created_at, finished_at)` +
+		` SELECT id, type, payload, run_at, attempts, max_attempts, unique_key, ?, ?, created_at, ? FROM {table} WHERE id = ? AND lease_owner = ?`)
+	if err != nil {
+		return err
+	}
+	del, err := s.query(`DELETE FROM {table} WHERE id = ?`)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, ins, lastErr, status, time.Now(), job.ID, job.LeaseOwner)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return ErrLeaseLost
+	}
+	if _, err := tx.ExecContext(ctx, del, job.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
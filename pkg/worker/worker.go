@@ -23,6 +23,7 @@ func (f JobFunc) Run(ctx context.Context) error { return f(ctx) }
 type Config struct {
 	Concurrency int
 	Retry       retry.Config
+	RateLimit   RateLimit // optional; waits for a token before each attempt
 }
 
 // DefaultConfig returns default configuration (4 workers, default retry).
@@ -37,6 +38,7 @@ func DefaultConfig() Config {
 type Pool struct {
 	cfg   Config
 	jobs  <-chan Job
+	limit *limiter
 	done  chan struct{}
 	wg    sync.WaitGroup
 	start sync.Once
@@ -48,7 +50,7 @@ func NewPool(cfg Config, jobsCh <-chan Job) *Pool {
 	if cfg.Concurrency < 1 {
 		cfg.Concurrency = 1
 	}
-	return &Pool{cfg: cfg, jobs: jobsCh, done: make(chan struct{})}
+	return &Pool{cfg: cfg, jobs: jobsCh, limit: newLimiter(cfg.RateLimit), done: make(chan struct{})}
 }
 
 // Start starts the workers. Returns immediately.
@@ -73,7 +75,16 @@ func (p *Pool) worker(ctx context.Context) {
 			if !ok {
 				return
 			}
-			_ = retry.Do(ctx, p.cfg.Retry, func() error { return job.Run(ctx) })
+			key := rateKey(job)
+			err := retry.Do(ctx, p.cfg.Retry, func() error {
+				if err := p.limit.wait(ctx, key); err != nil {
+					return err
+				}
+				return job.Run(ctx)
+			})
+			if c, ok := job.(completer); ok {
+				c.complete(ctx, err, p.done)
+			}
 		}
 	}
 }